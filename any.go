@@ -0,0 +1,147 @@
+package pbr
+
+import (
+	"errors"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ErrUnknownAnyType is returned when a resolver
+// has no handler for the type of an Any message.
+var ErrUnknownAnyType = errors.New("protoscan: unknown any type")
+
+// AnyHandler handles the value of a google.protobuf.Any message.
+// The payload is a scanner for the encoded value message.
+type AnyHandler func(typeURL string, payload *Message) error
+
+// AnyResolver finds the handler for the type URL of an Any message.
+type AnyResolver interface {
+	ResolveAny(typeURL string) (AnyHandler, error)
+}
+
+// AnyHandlers is an AnyResolver using a map from type URL to handler.
+// If the full type URL is not found the full message name,
+// the part after the last '/', is tried.
+type AnyHandlers map[string]AnyHandler
+
+// AnyRegistry is an AnyResolver that finds the descriptor of the
+// Any value in a registry and passes it to the handler.
+// This allows for generic handlers like transcoders.
+type AnyRegistry struct {
+	// Files is the registry of the message descriptors,
+	// protoregistry.GlobalFiles is used if nil.
+	Files  *protoregistry.Files
+	Handle func(md protoreflect.MessageDescriptor, payload *Message) error
+}
+
+// Any reads an embedded google.protobuf.Any message and returns
+// the type URL and a scanner for the encoded value.
+// Will reuse the provided Message object if provided.
+func (m *Message) Any(payload *Message) (string, *Message, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		typeURL string
+		value   []byte
+	)
+
+	msg := New(data)
+	for msg.Next() {
+		switch {
+		case msg.fieldNumber == 1 && msg.wireType == WireTypeLengthDelimited:
+			typeURL, err = msg.String()
+		case msg.fieldNumber == 2 && msg.wireType == WireTypeLengthDelimited:
+			value, err = msg.Bytes()
+		default:
+			msg.Skip()
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if msg.err != nil {
+		return "", nil, msg.err
+	}
+
+	if payload == nil {
+		payload = New(value)
+	} else {
+		payload.Reset(value)
+		// Reset keeps the old data when the new data is nil.
+		payload.Data = value
+	}
+
+	return typeURL, payload, nil
+}
+
+// DispatchAny reads an embedded google.protobuf.Any message and
+// calls the handler returned by the resolver for its type.
+func (m *Message) DispatchAny(r AnyResolver) error {
+	typeURL, payload, err := m.Any(nil)
+	if err != nil {
+		return err
+	}
+
+	h, err := r.ResolveAny(typeURL)
+	if err != nil {
+		return err
+	}
+
+	return h(typeURL, payload)
+}
+
+// ResolveAny returns the handler for the type URL.
+func (h AnyHandlers) ResolveAny(typeURL string) (AnyHandler, error) {
+	if handler, ok := h[typeURL]; ok {
+		return handler, nil
+	}
+
+	if handler, ok := h[anyMessageName(typeURL)]; ok {
+		return handler, nil
+	}
+
+	return nil, ErrUnknownAnyType
+}
+
+// ResolveAny returns a handler that calls Handle
+// with the message descriptor of the type URL.
+func (r *AnyRegistry) ResolveAny(typeURL string) (AnyHandler, error) {
+	files := r.Files
+	if files == nil {
+		files = protoregistry.GlobalFiles
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(anyMessageName(typeURL)))
+	if err == protoregistry.NotFound {
+		return nil, ErrUnknownAnyType
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, ErrUnknownAnyType
+	}
+
+	return func(_ string, payload *Message) error {
+		return r.Handle(md, payload)
+	}, nil
+}
+
+// anyMessageName returns the full message name of the type URL.
+func anyMessageName(typeURL string) string {
+	if i := strings.LastIndexByte(typeURL, '/'); i >= 0 {
+		return typeURL[i+1:]
+	}
+
+	return typeURL
+}
@@ -0,0 +1,93 @@
+package pbr
+
+import (
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMessage_Any(t *testing.T) {
+	value, err := anypb.New(&testmsg.Customer{Id: 123})
+	if err != nil {
+		t.Fatalf("unable to create any: %e", err)
+	}
+
+	msg := embedded(t, value)
+	typeURL, payload, err := msg.Any(nil)
+	if err != nil {
+		t.Fatalf("unable to read any: %e", err)
+	}
+
+	if typeURL != "type.googleapis.com/testmsg.Customer" {
+		t.Errorf("incorrect type url: %v", typeURL)
+	}
+
+	payload.Next()
+	if id, err := payload.Int64(); err != nil || id != 123 {
+		t.Errorf("incorrect id: %v %e", id, err)
+	}
+
+	// reuse with an empty value
+	empty, _ := anypb.New(&testmsg.Item{})
+	_, payload, err = embedded(t, empty).Any(payload)
+	if err != nil {
+		t.Fatalf("unable to read any: %e", err)
+	}
+
+	if payload.Next() {
+		t.Errorf("payload should be empty")
+	}
+}
+
+func TestMessage_DispatchAny(t *testing.T) {
+	value, _ := anypb.New(&testmsg.Customer{Id: 123})
+
+	t.Run("handlers", func(t *testing.T) {
+		var id int64
+		handlers := AnyHandlers{
+			"testmsg.Customer": func(typeURL string, payload *Message) (err error) {
+				payload.Next()
+				id, err = payload.Int64()
+				return err
+			},
+		}
+
+		if err := embedded(t, value).DispatchAny(handlers); err != nil {
+			t.Fatalf("unexpected error: %e", err)
+		}
+
+		if id != 123 {
+			t.Errorf("incorrect id: %v", id)
+		}
+
+		other, _ := anypb.New(&testmsg.Item{Id: 1})
+		if err := embedded(t, other).DispatchAny(handlers); err != ErrUnknownAnyType {
+			t.Errorf("incorrect error: %v", err)
+		}
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		var name protoreflect.FullName
+		registry := &AnyRegistry{
+			Handle: func(md protoreflect.MessageDescriptor, payload *Message) error {
+				name = md.FullName()
+				return nil
+			},
+		}
+
+		if err := embedded(t, value).DispatchAny(registry); err != nil {
+			t.Fatalf("unexpected error: %e", err)
+		}
+
+		if name != "testmsg.Customer" {
+			t.Errorf("incorrect name: %v", name)
+		}
+
+		unknown := &anypb.Any{TypeUrl: "type.googleapis.com/unknown.Message"}
+		if err := embedded(t, unknown).DispatchAny(registry); err != ErrUnknownAnyType {
+			t.Errorf("incorrect error: %v", err)
+		}
+	})
+}
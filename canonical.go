@@ -0,0 +1,567 @@
+package pbr
+
+import (
+	"bytes"
+	"slices"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Canonicalize re-encodes the message so that messages with the same
+// content have the same encoding. Fields are sorted by number,
+// varints use the minimal encoding, repeated scalars are packed,
+// duplicate non-repeated fields are resolved and embedded messages
+// are merged and canonicalized recursively.
+// With a descriptor, fields without presence that have the default
+// value are removed, map entries are sorted by key and only
+// the member of a oneof that occurs last is kept.
+// Unknown fields, not in the schema, are kept in order.
+func Canonicalize(data []byte, s *Schema) ([]byte, error) {
+	return appendCanonical(nil, [][]byte{data}, s)
+}
+
+// IsCanonical returns true if the message is encoded as Canonicalize would.
+// The message is checked with a single scan, nothing is encoded.
+func IsCanonical(data []byte, s *Schema) (bool, error) {
+	c := &canonicalChecker{}
+	ok := c.message(data, s)
+	return ok, c.err
+}
+
+func appendCanonical(buf []byte, chunks [][]byte, s *Schema) ([]byte, error) {
+	v, err := Merged(s, chunks...)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := slices.Clone(v.numbers)
+	slices.Sort(numbers)
+
+	for _, n := range numbers {
+		occs := v.fields[n]
+		fd := s.field(n)
+
+		if !v.schema.known(n) {
+			for _, o := range occs {
+				if buf, err = appendMinimal(buf, n, o); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if sub, ok := s.message(n); ok {
+			payloads, err := v.payloads(n)
+			if err != nil {
+				return nil, err
+			}
+
+			if !s.repeated(n) {
+				data, err := appendCanonical(nil, payloads, sub)
+				if err != nil {
+					return nil, err
+				}
+				buf = appendBytes(buf, n, data)
+				continue
+			}
+
+			if fd != nil && fd.IsMap() {
+				if buf, err = appendCanonicalMap(buf, n, payloads, sub, fd.MapKey()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			for _, p := range payloads {
+				data, err := appendCanonical(nil, [][]byte{p}, sub)
+				if err != nil {
+					return nil, err
+				}
+				buf = appendBytes(buf, n, data)
+			}
+			continue
+		}
+
+		if s.repeated(n) {
+			wireType, err := packedWireType(s, n, occs)
+			if err != nil {
+				return nil, err
+			}
+
+			if wireType < 0 {
+				for _, o := range occs {
+					if buf, err = appendMinimal(buf, n, o); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+
+			var packed []byte
+			for _, o := range occs {
+				if packed, err = appendPackedValues(packed, wireType, n, o); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(packed) > 0 {
+				buf = appendBytes(buf, n, packed)
+			}
+			continue
+		}
+
+		// non-repeated scalar, the last one wins
+		o := occs[len(occs)-1]
+		if fd != nil && !fd.HasPresence() && isZeroValue(o) {
+			continue
+		}
+
+		if buf, err = appendMinimal(buf, n, o); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// appendCanonicalMap appends the map entries canonicalized,
+// without duplicate keys and sorted by key.
+func appendCanonicalMap(buf []byte, n int, payloads [][]byte, entry *Schema, key protoreflect.FieldDescriptor) ([]byte, error) {
+	entries := make([][]byte, 0, len(payloads))
+	keys := make(map[string]int, len(payloads))
+	for _, p := range payloads {
+		data, err := appendCanonical(nil, [][]byte{p}, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := mapKey(data)
+		if err != nil {
+			return nil, err
+		}
+
+		// the last entry for a key wins
+		if i, ok := keys[string(k)]; ok {
+			entries[i] = data
+			continue
+		}
+
+		keys[string(k)] = len(entries)
+		entries = append(entries, data)
+	}
+
+	var err error
+	slices.SortFunc(entries, func(a, b []byte) int {
+		ka, erra := mapKey(a)
+		kb, errb := mapKey(b)
+		if erra != nil || errb != nil {
+			err = ErrInvalidLength
+			return 0
+		}
+
+		return compareMapKeys(ka, kb, key.Kind())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		buf = appendBytes(buf, n, e)
+	}
+
+	return buf, nil
+}
+
+// mapKey returns the encoded value of the key of a canonical map entry.
+// A missing key is the default value and returns nil.
+func mapKey(entry []byte) ([]byte, error) {
+	msg := New(entry)
+	for msg.Next() {
+		if msg.fieldNumber != 1 {
+			msg.Skip()
+			continue
+		}
+
+		start := msg.Index
+		msg.Skip()
+		if msg.err != nil {
+			return nil, msg.err
+		}
+
+		return entry[start:msg.Index], nil
+	}
+
+	return nil, msg.err
+}
+
+// compareMapKeys compares the encoded values of two map keys.
+func compareMapKeys(a, b []byte, kind protoreflect.Kind) int {
+	switch kind {
+	case protoreflect.StringKind:
+		return bytes.Compare(lengthDelimitedValue(a), lengthDelimitedValue(b))
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		va, vb := fixedValue(a), fixedValue(b)
+		if kind == protoreflect.Sfixed32Kind {
+			return compareInts(int64(int32(va)), int64(int32(vb)))
+		}
+
+		if kind == protoreflect.Sfixed64Kind {
+			return compareInts(int64(va), int64(vb))
+		}
+
+		return compareUints(va, vb)
+	}
+
+	var va, vb uint64
+	if len(a) > 0 {
+		_, va, _ = varint64(a, 0)
+	}
+
+	if len(b) > 0 {
+		_, vb, _ = varint64(b, 0)
+	}
+
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		return compareInts(int64(va), int64(vb))
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		return compareInts(unZig64(va), unZig64(vb))
+	}
+
+	return compareUints(va, vb)
+}
+
+// lengthDelimitedValue returns the data of an encoded
+// length-delimited value without the length.
+func lengthDelimitedValue(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+
+	i, _, err := varint64(b, 0)
+	if err != nil {
+		return nil
+	}
+
+	return b[i:]
+}
+
+func fixedValue(b []byte) uint64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+
+	return v
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func compareUints(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// packedWireType returns the wire type of the values of a repeated field
+// that can be packed, or -1 if it can not be packed.
+// Without a descriptor the wire type of the unpacked occurrences is used,
+// if they are all length-delimited they could be strings or messages.
+func packedWireType(s *Schema, n int, occs []occurrence) (int, error) {
+	if fd := s.field(n); fd != nil {
+		switch wireType := kindWireType(fd.Kind()); wireType {
+		case WireTypeVarint, WireType32bit, WireType64bit:
+			return wireType, nil
+		}
+
+		return -1, nil
+	}
+
+	wireType := -1
+	for _, o := range occs {
+		switch o.wireType {
+		case WireTypeLengthDelimited:
+			continue
+		case WireTypeVarint, WireType32bit, WireType64bit:
+			if wireType >= 0 && wireType != o.wireType {
+				return -1, ErrInvalidWireType
+			}
+			wireType = o.wireType
+		default:
+			return -1, nil
+		}
+	}
+
+	return wireType, nil
+}
+
+// appendPackedValues appends the values of an occurrence
+// without the tags, the occurrence can be packed or not.
+func appendPackedValues(buf []byte, wireType, n int, o occurrence) ([]byte, error) {
+	msg := fieldScanner(o.data, n, o.field)
+	if o.wireType == wireType {
+		return appendValue(buf, &msg.base, wireType)
+	}
+
+	if o.wireType != WireTypeLengthDelimited {
+		return nil, ErrInvalidWireType
+	}
+
+	iter, err := msg.Iterator(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for iter.HasNext() {
+		if buf, err = appendValue(buf, &iter.base, wireType); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// appendValue reads a single value and appends its minimal encoding.
+func appendValue(buf []byte, b *base, wireType int) ([]byte, error) {
+	switch wireType {
+	case WireTypeVarint:
+		v, err := b.Varint64()
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, v), nil
+	case WireType32bit:
+		v, err := b.Fixed32()
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed32(buf, v), nil
+	case WireType64bit:
+		v, err := b.Fixed64()
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed64(buf, v), nil
+	}
+
+	return nil, ErrInvalidWireType
+}
+
+// appendMinimal appends the occurrence using minimal varints.
+func appendMinimal(buf []byte, n int, o occurrence) ([]byte, error) {
+	buf = appendTag(buf, n, o.wireType)
+	msg := fieldScanner(o.data, n, o.field)
+	switch o.wireType {
+	case WireTypeVarint, WireType32bit, WireType64bit:
+		return appendValue(buf, &msg.base, o.wireType)
+	case WireTypeLengthDelimited:
+		v, err := msg.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		buf = appendVarint(buf, uint64(len(v)))
+		return append(buf, v...), nil
+	}
+
+	// groups are copied unchanged up to the end group tag
+	return append(buf, o.data[o.value:o.end]...), nil
+}
+
+// isZeroValue returns true if the value of the occurrence is the default.
+func isZeroValue(o occurrence) bool {
+	v := o.data[o.value:o.end]
+	switch o.wireType {
+	case WireTypeVarint:
+		_, x, err := varint64(v, 0)
+		return err == nil && x == 0
+	case WireType32bit, WireType64bit:
+		return fixedValue(v) == 0
+	case WireTypeLengthDelimited:
+		_, x, err := varint64(v, 0)
+		return err == nil && x == 0
+	}
+
+	return false
+}
+
+// canonicalChecker checks the canonical encoding rules while scanning.
+type canonicalChecker struct {
+	err error
+}
+
+func (c *canonicalChecker) message(data []byte, s *Schema) bool {
+	var (
+		prev       int
+		prevKey    []byte
+		hasPrevKey bool
+		oneofs     map[int]bool // indexes of the oneofs with a member
+	)
+
+	msg := New(data)
+	for {
+		start := msg.Index
+		if !msg.Next() {
+			break
+		}
+
+		n := msg.fieldNumber
+		tag := uint64(n)<<3 | uint64(msg.wireType)
+		if msg.Index-start != sizeVarint(tag) || n < prev {
+			return false
+		}
+
+		// only unknown and non-packable repeated fields can repeat
+		known := s.known(n)
+		sub, isMessage := s.message(n)
+		packable := false
+		if known && s.repeated(n) && !isMessage {
+			wireType, err := packedWireType(s, n, []occurrence{{field: field{wireType: msg.wireType}}})
+			if err != nil {
+				c.err = err
+				return false
+			}
+
+			packable = wireType >= 0
+			if packable && msg.wireType != WireTypeLengthDelimited {
+				return false
+			}
+		}
+
+		if n == prev && known && (!s.repeated(n) || packable) {
+			return false
+		}
+
+		// only one member of a oneof can be set
+		if fd := s.field(n); fd != nil && n != prev {
+			if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+				if oneofs[od.Index()] {
+					return false
+				}
+
+				if oneofs == nil {
+					oneofs = make(map[int]bool)
+				}
+				oneofs[od.Index()] = true
+			}
+		}
+
+		if n != prev {
+			hasPrevKey = false
+		}
+		prev = n
+
+		valueStart := msg.Index
+		switch {
+		case msg.wireType == WireTypeVarint:
+			v, err := msg.Varint64()
+			if err != nil {
+				c.err = err
+				return false
+			}
+
+			fd := s.field(n)
+			if msg.Index-valueStart != sizeVarint(v) || (v == 0 && fd != nil && !fd.IsList() && !fd.HasPresence()) {
+				return false
+			}
+		case msg.wireType == WireTypeLengthDelimited:
+			l, err := msg.packedLength()
+			if err != nil {
+				c.err = err
+				return false
+			}
+
+			if msg.Index-valueStart != sizeVarint(uint64(l)) {
+				return false
+			}
+
+			fd := s.field(n)
+			if l == 0 && fd != nil && !fd.HasPresence() && (!fd.IsList() || packable) {
+				return false
+			}
+
+			payload := msg.Data[msg.Index : msg.Index+l]
+			msg.Index += l
+
+			switch {
+			case isMessage:
+				if !c.message(payload, sub) {
+					return false
+				}
+
+				if fd != nil && fd.IsMap() {
+					key, err := mapKey(payload)
+					if err != nil {
+						c.err = err
+						return false
+					}
+
+					if hasPrevKey && compareMapKeys(prevKey, key, fd.MapKey().Kind()) >= 0 {
+						return false
+					}
+					prevKey, hasPrevKey = key, true
+				}
+			case packable:
+				if !c.packed(payload, s, n) {
+					return false
+				}
+			}
+		case msg.wireType == WireTypeStartGroup:
+			skipGroup(msg, n) // groups are kept unchanged
+		case msg.wireType == WireTypeEndGroup:
+			c.err = ErrInvalidWireType
+			return false
+		default:
+			msg.Skip()
+			fd := s.field(n)
+			if msg.err == nil && fd != nil && !fd.IsList() && !fd.HasPresence() &&
+				isZeroValue(occurrence{data: data, field: field{value: valueStart, end: msg.Index, wireType: msg.wireType}}) {
+				return false
+			}
+		}
+	}
+
+	if msg.err != nil {
+		c.err = msg.err
+		return false
+	}
+
+	return true
+}
+
+// packed checks that the varints of a packed field are minimal.
+func (c *canonicalChecker) packed(payload []byte, s *Schema, n int) bool {
+	fd := s.field(n)
+	if fd == nil || kindWireType(fd.Kind()) != WireTypeVarint {
+		return true
+	}
+
+	for i := 0; i < len(payload); {
+		next, v, err := varint64(payload, i)
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		if next-i != sizeVarint(v) {
+			return false
+		}
+		i = next
+	}
+
+	return true
+}
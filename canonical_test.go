@@ -0,0 +1,243 @@
+package pbr
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCanonicalize(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          150,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1, Open: true}, {Id: 2}},
+		FavoriteIds: []int64{1, 300, 3},
+	}
+	s := &Schema{Descriptor: c.ProtoReflect().Descriptor()}
+
+	standard, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	// same content encoded differently
+	var other []byte
+	other = appendTag(other, 4, WireTypeVarint)
+	other = append(other, 0x81, 0x00) // non-minimal 1
+	other = appendBytes(other, 3, []byte{0x10, 0x01, 0x08, 0x01})
+	other = appendTag(other, 1, WireTypeVarint)
+	other = appendVarint(other, 7) // the last id wins
+	other = appendBytes(other, 4, []byte{0xac, 0x02, 0x03})
+	other = appendBytes(other, 3, []byte{0x08, 0x02, 0x10, 0x00})
+	other = append(other, 0x92, 0x00, 0x04, 'n', 'a', 'm', 'e') // non-minimal tag
+	other = appendTag(other, 1, WireTypeVarint)
+	other = appendVarint(other, 150)
+
+	check := &testmsg.Customer{}
+	if err := proto.Unmarshal(other, check); err != nil || !proto.Equal(check, c) {
+		t.Fatalf("test data is not equal: %v %e", check, err)
+	}
+
+	a, err := Canonicalize(standard, s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	b, err := Canonicalize(other, s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	if !bytes.Equal(a, b) {
+		t.Errorf("encodings should be equal:\n%x\n%x", a, b)
+	}
+
+	result := &testmsg.Customer{}
+	if err := proto.Unmarshal(a, result); err != nil || !proto.Equal(result, c) {
+		t.Errorf("incorrect result: %v %e", result, err)
+	}
+
+	if again, _ := Canonicalize(a, s); !bytes.Equal(again, a) {
+		t.Errorf("should be idempotent")
+	}
+
+	if ok, err := IsCanonical(a, s); !ok || err != nil {
+		t.Errorf("should be canonical: %v %e", ok, err)
+	}
+
+	if ok, err := IsCanonical(other, s); ok || err != nil {
+		t.Errorf("should not be canonical: %v %e", ok, err)
+	}
+}
+
+func TestCanonicalize_defaults(t *testing.T) {
+	s := &Schema{Descriptor: (&testmsg.Scalar{}).ProtoReflect().Descriptor()}
+
+	var data []byte
+	data = appendTag(data, 4, WireTypeVarint)
+	data = appendVarint(data, 0)
+	data = appendBytes(data, 14, nil)
+	data = appendTag(data, 10, WireType64bit)
+	data = appendFixed64(data, 0)
+
+	if ok, _ := IsCanonical(data, s); ok {
+		t.Errorf("defaults should not be canonical")
+	}
+
+	result, err := Canonicalize(data, s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	if len(result) != 0 {
+		t.Errorf("defaults should be removed: %x", result)
+	}
+}
+
+func TestCanonicalize_map(t *testing.T) {
+	m := dynamicpb.NewMessage(wellKnownMessage)
+	err := prototext.Unmarshal([]byte(`
+		counts { key: "b" value: 2 }
+		counts { key: "a" value: 1 }
+		counts { key: "b" value: 3 }
+		children { key: true }
+		children { key: false value { i64 { value: 5 } } }
+	`), m)
+	if err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	s := &Schema{Descriptor: wellKnownMessage}
+	data, _ := proto.Marshal(m)
+
+	// the map entries in the reverse order and duplicated
+	var reversed []byte
+	idx, _ := Index(data)
+	for _, n := range []int{8, 9} {
+		entries := idx.LookupAll(n)
+		for i := len(entries) - 1; i >= 0; i-- {
+			reversed = append(reversed, entries[i].Data...)
+		}
+	}
+	reversed = append(reversed, idx.LookupAll(8)[0].Data...)
+
+	a, err := Canonicalize(data, s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	b, err := Canonicalize(reversed, s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	if !bytes.Equal(a, b) {
+		t.Errorf("encodings should be equal:\n%x\n%x", a, b)
+	}
+
+	text, _ := Text(a, wellKnownMessage)
+	expected := "counts {\n  key: \"a\"\n  value: 1\n}\ncounts {\n  key: \"b\"\n  value: 3\n}\n" +
+		"children {\n  value {\n    i64 {\n      value: 5\n    }\n  }\n}\nchildren {\n  key: true\n  value {\n  }\n}\n"
+	if string(text) != expected {
+		t.Errorf("incorrect result:\n%s", text)
+	}
+
+	if ok, err := IsCanonical(a, s); !ok || err != nil {
+		t.Errorf("should be canonical: %v %e", ok, err)
+	}
+
+	if ok, err := IsCanonical(reversed, s); ok || err != nil {
+		t.Errorf("should not be canonical: %v %e", ok, err)
+	}
+}
+
+func TestCanonicalize_schema(t *testing.T) {
+	var data []byte
+	data = appendTag(data, 2, WireTypeVarint)
+	data = append(data, 0x85, 0x80, 0x00) // non-minimal 5
+	data = appendTag(data, 1, WireTypeVarint)
+	data = appendVarint(data, 1)
+	data = appendBytes(data, 1, []byte{2, 3})
+	data = appendTag(data, 1, WireTypeVarint)
+	data = appendVarint(data, 4)
+	data = appendBytes(data, 3, []byte("a"))
+	data = appendBytes(data, 3, []byte("b"))
+
+	t.Run("explicit", func(t *testing.T) {
+		s := &Schema{Repeated: map[int]bool{1: true, 3: true}}
+		result, err := Canonicalize(data, s)
+		if err != nil {
+			t.Fatalf("unable to canonicalize: %e", err)
+		}
+
+		expected := []byte{0x0a, 0x04, 1, 2, 3, 4, 0x10, 0x05, 0x1a, 0x01, 'a', 0x1a, 0x01, 'b'}
+		if !bytes.Equal(result, expected) {
+			t.Errorf("incorrect result: %x", result)
+		}
+
+		if ok, _ := IsCanonical(result, s); !ok {
+			t.Errorf("should be canonical")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		result, err := Canonicalize(data, nil)
+		if err != nil {
+			t.Fatalf("unable to canonicalize: %e", err)
+		}
+
+		expected := []byte{0x08, 0x01, 0x0a, 0x02, 2, 3, 0x08, 0x04, 0x10, 0x05, 0x1a, 0x01, 'a', 0x1a, 0x01, 'b'}
+		if !bytes.Equal(result, expected) {
+			t.Errorf("incorrect result: %x", result)
+		}
+
+		if ok, _ := IsCanonical(result, nil); !ok {
+			t.Errorf("should be canonical")
+		}
+	})
+
+	if _, err := Canonicalize([]byte{0x0a, 0x05}, nil); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	if _, err := IsCanonical([]byte{0x0a, 0x05}, nil); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestCanonicalize_oneof(t *testing.T) {
+	s := &Schema{Descriptor: (&structpb.Value{}).ProtoReflect().Descriptor()}
+
+	var dst, src []byte
+	dst = appendBytes(dst, 3, []byte("a"))
+	dst = appendTag(dst, 4, WireTypeVarint)
+	dst = appendVarint(dst, 1)
+	src = appendBytes(src, 3, []byte("b"))
+
+	result, err := Canonicalize(append(dst, src...), s)
+	if err != nil {
+		t.Fatalf("unable to canonicalize: %e", err)
+	}
+
+	v := &structpb.Value{}
+	if err := proto.Unmarshal(result, v); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	if v.GetStringValue() != "b" {
+		t.Errorf("incorrect value: %v", v)
+	}
+
+	if ok, _ := IsCanonical(result, s); !ok {
+		t.Errorf("should be canonical")
+	}
+
+	if ok, _ := IsCanonical(dst, s); ok {
+		t.Errorf("should not be canonical with two oneof members")
+	}
+}
@@ -0,0 +1,110 @@
+// Command pbrdiff prints the semantic differences between two encoded
+// protobuf messages.
+//
+// Usage:
+//
+//	pbrdiff [-desc file.pb -type package.Message] old.bin new.bin
+//
+// The descriptor set is a serialized google.protobuf.FileDescriptorSet,
+// e.g. generated with 'protoc --include_imports -o file.pb'.
+// Without a descriptor fields are printed by number and embedded
+// messages are detected heuristically.
+// The exit status is 1 if the messages differ and 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pchchv/pbr"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func main() {
+	desc := flag.String("desc", "", "serialized FileDescriptorSet with the message type")
+	typ := flag.String("type", "", "full name of the message type, required with -desc")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-desc file.pb -type package.Message] old new\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	changes, err := run(*desc, *typ, flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pbrdiff:", err)
+		os.Exit(2)
+	}
+
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(desc, typ, oldPath, newPath string) ([]pbr.Change, error) {
+	var schema *pbr.Schema
+	if desc != "" {
+		md, err := loadDescriptor(desc, typ)
+		if err != nil {
+			return nil, err
+		}
+		schema = &pbr.Schema{Descriptor: md}
+	}
+
+	a, err := os.ReadFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return pbr.Diff(a, b, schema)
+}
+
+func loadDescriptor(path, typ string) (protoreflect.MessageDescriptor, error) {
+	if typ == "" {
+		return nil, fmt.Errorf("-type is required with -desc")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("unable to read descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load descriptor set: %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(typ))
+	if err != nil {
+		return nil, err
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", typ)
+	}
+
+	return md, nil
+}
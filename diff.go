@@ -0,0 +1,521 @@
+package pbr
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ChangeKind is the kind of change of a field between two messages.
+type ChangeKind int
+
+const (
+	// Added is a field that is only in the new message.
+	Added ChangeKind = iota + 1
+	// Removed is a field that is only in the old message.
+	Removed
+	// Modified is a field with a different value in the new message.
+	Modified
+)
+
+// Change is a difference between two messages.
+// The path contains the field names, or numbers if the field is unknown,
+// and the index of repeated values or the key of map values,
+// e.g. 'orders[1].id'.
+// Values are decoded by kind with a descriptor, enums as their names,
+// without one varints are uint64, fixed values are uint32 or uint64 and
+// length-delimited values are strings if they are valid UTF-8.
+// Added or removed embedded messages have the encoded message as the value.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Old  any
+	New  any
+}
+
+// Diff compares two encoded messages and returns the changes from a to b.
+// Field order and packed or unpacked encoding of repeated fields
+// don't matter. Embedded messages are compared recursively, without
+// a schema length-delimited values that look like messages are
+// assumed to be embedded messages.
+// With a descriptor, a missing field without presence is compared
+// as its default value and map keys are sorted by value.
+func Diff(a, b []byte, s *Schema) ([]Change, error) {
+	d := &differ{}
+	if err := d.message("", [][]byte{a}, [][]byte{b}, s); err != nil {
+		return nil, err
+	}
+
+	return d.changes, nil
+}
+
+// String returns the change in a format like '~ path: old -> new'.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return "+ " + c.Path + ": " + formatValue(c.New)
+	case Removed:
+		return "- " + c.Path + ": " + formatValue(c.Old)
+	}
+
+	return "~ " + c.Path + ": " + formatValue(c.Old) + " -> " + formatValue(c.New)
+}
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// diffValue is a decoded value, or the chunks of an embedded message.
+type diffValue struct {
+	value    any
+	message  [][]byte
+	isMsg    bool
+	rawBytes bool // length-delimited value of an unknown field
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) message(path string, a, b [][]byte, s *Schema) error {
+	va, err := Merged(s, a...)
+	if err != nil {
+		return err
+	}
+
+	vb, err := Merged(s, b...)
+	if err != nil {
+		return err
+	}
+
+	numbers := slices.Clone(va.numbers)
+	for _, n := range vb.numbers {
+		if !va.Has(n) {
+			numbers = append(numbers, n)
+		}
+	}
+	slices.Sort(numbers)
+
+	for _, n := range numbers {
+		fd := s.field(n)
+		name := path + strconv.Itoa(n)
+		if fd != nil {
+			name = path + string(fd.Name())
+		}
+
+		// the wire type of unpacked values is used to unpack the
+		// values of unknown fields that are packed on the other side.
+		hint := -1
+		for _, o := range append(slices.Clone(va.fields[n]), vb.fields[n]...) {
+			if o.wireType != WireTypeLengthDelimited {
+				hint = o.wireType
+			}
+		}
+
+		if fd != nil && fd.IsMap() {
+			if err := d.mapField(name, n, va, vb, fd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		xs, err := diffValues(va, n, hint)
+		if err != nil {
+			return err
+		}
+
+		ys, err := diffValues(vb, n, hint)
+		if err != nil {
+			return err
+		}
+
+		if s.known(n) && !s.repeated(n) {
+			x, y := last(xs), last(ys)
+			// without presence a missing value is the default value
+			if fd != nil && !fd.HasPresence() {
+				if x == nil {
+					x = &diffValue{value: defaultValue(fd)}
+				}
+
+				if y == nil {
+					y = &diffValue{value: defaultValue(fd)}
+				}
+			}
+
+			if err := d.compare(name, x, y, s, n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i := 0; i < len(xs) || i < len(ys); i++ {
+			var x, y *diffValue
+			if i < len(xs) {
+				x = &xs[i]
+			}
+
+			if i < len(ys) {
+				y = &ys[i]
+			}
+
+			if err := d.compare(name+"["+strconv.Itoa(i)+"]", x, y, s, n); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *differ) mapField(name string, n int, va, vb *MergedView, fd protoreflect.FieldDescriptor) error {
+	entry := &Schema{Descriptor: fd.Message()}
+	xs, xkeys, err := mapValues(va, n, entry, fd)
+	if err != nil {
+		return err
+	}
+
+	ys, ykeys, err := mapValues(vb, n, entry, fd)
+	if err != nil {
+		return err
+	}
+
+	keys := slices.Clone(xkeys)
+	for _, k := range ykeys {
+		if _, ok := xs[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b any) int {
+		return compareKeys(reflect.ValueOf(a), reflect.ValueOf(b))
+	})
+
+	for _, k := range keys {
+		var x, y *diffValue
+		if v, ok := xs[k]; ok {
+			x = &v
+		}
+
+		if v, ok := ys[k]; ok {
+			y = &v
+		}
+
+		if err := d.compare(name+"["+fmt.Sprint(k)+"]", x, y, entry, 2); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compare adds the change between two values of the field, nil if not present.
+func (d *differ) compare(path string, x, y *diffValue, s *Schema, n int) error {
+	switch {
+	case x == nil && y == nil:
+		return nil
+	case x == nil:
+		d.changes = append(d.changes, Change{Path: path, Kind: Added, New: y.result()})
+		return nil
+	case y == nil:
+		d.changes = append(d.changes, Change{Path: path, Kind: Removed, Old: x.result()})
+		return nil
+	}
+
+	if x.isMsg && y.isMsg {
+		sub, _ := s.message(n)
+		return d.message(path+".", x.message, y.message, sub)
+	}
+
+	// without a schema values that look like messages are compared as messages
+	if x.rawBytes && y.rawBytes {
+		xb, yb := x.value.([]byte), y.value.([]byte)
+		if looksLikeMessage(xb) && looksLikeMessage(yb) {
+			return d.message(path+".", [][]byte{xb}, [][]byte{yb}, nil)
+		}
+	}
+
+	if !valuesEqual(x.result(), y.result()) {
+		d.changes = append(d.changes, Change{Path: path, Kind: Modified, Old: x.result(), New: y.result()})
+	}
+
+	return nil
+}
+
+// result returns the value for a change.
+func (v *diffValue) result() any {
+	if v.isMsg {
+		return bytes.Join(v.message, nil)
+	}
+
+	if b, ok := v.value.([]byte); ok && v.rawBytes && utf8.Valid(b) {
+		return string(b)
+	}
+
+	return v.value
+}
+
+// diffValues returns the values of all the occurrences of the field.
+// Non-repeated embedded messages are returned as one value.
+func diffValues(v *MergedView, n int, hint int) ([]diffValue, error) {
+	occs := v.fields[n]
+	if len(occs) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := v.schema.message(n); ok {
+		payloads, err := v.payloads(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if !v.schema.repeated(n) {
+			return []diffValue{{message: payloads, isMsg: true}}, nil
+		}
+
+		result := make([]diffValue, 0, len(payloads))
+		for _, p := range payloads {
+			result = append(result, diffValue{message: [][]byte{p}, isMsg: true})
+		}
+
+		return result, nil
+	}
+
+	fd := v.schema.field(n)
+	var result []diffValue
+	for _, o := range occs {
+		msg := fieldScanner(o.data, n, o.field)
+		if fd != nil {
+			if o.wireType == WireTypeLengthDelimited && kindWireType(fd.Kind()) != WireTypeLengthDelimited {
+				iter, err := msg.Iterator(nil)
+				if err != nil {
+					return nil, err
+				}
+
+				for iter.HasNext() {
+					value, err := scalarValue(&iter.base, fd)
+					if err != nil {
+						return nil, err
+					}
+					result = append(result, diffValue{value: value})
+				}
+				continue
+			}
+
+			var (
+				value any
+				err   error
+			)
+			switch fd.Kind() {
+			case protoreflect.StringKind:
+				value, err = msg.String()
+			case protoreflect.BytesKind:
+				value, err = msg.Bytes()
+			default:
+				value, err = scalarValue(&msg.base, fd)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, diffValue{value: value})
+			continue
+		}
+
+		if o.wireType == WireTypeLengthDelimited && hint >= 0 {
+			iter, err := msg.Iterator(nil)
+			if err != nil {
+				return nil, err
+			}
+
+			for iter.HasNext() {
+				value, err := wireValue(&iter.base, hint)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, diffValue{value: value})
+			}
+			continue
+		}
+
+		if o.wireType == WireTypeLengthDelimited {
+			value, err := msg.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, diffValue{value: value, rawBytes: true})
+			continue
+		}
+
+		value, err := wireValue(&msg.base, o.wireType)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, diffValue{value: value})
+	}
+
+	return result, nil
+}
+
+// mapValues returns the values of the map entries by the decoded key
+// and the keys in the order of their first occurrence.
+func mapValues(v *MergedView, n int, entry *Schema, fd protoreflect.FieldDescriptor) (map[any]diffValue, []any, error) {
+	payloads, err := v.payloads(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make(map[any]diffValue, len(payloads))
+	var keys []any
+	for _, p := range payloads {
+		ev, err := Merged(entry, p)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var key any = fd.MapKey().Default().Interface()
+		if ks, err := diffValues(ev, 1, -1); err != nil {
+			return nil, nil, err
+		} else if len(ks) > 0 {
+			key = ks[len(ks)-1].value
+		}
+
+		value := diffValue{value: defaultValue(fd.MapValue())}
+		if fd.MapValue().Message() != nil {
+			value = diffValue{isMsg: true}
+		}
+
+		if vs, err := diffValues(ev, 2, -1); err != nil {
+			return nil, nil, err
+		} else if len(vs) > 0 {
+			value = vs[len(vs)-1]
+		}
+
+		if _, ok := result[key]; !ok {
+			keys = append(keys, key)
+		}
+		result[key] = value
+	}
+
+	return result, keys, nil
+}
+
+// scalarValue reads a value that can be packed using the kind of the field.
+func scalarValue(b *base, fd protoreflect.FieldDescriptor) (any, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return b.Bool()
+	case protoreflect.EnumKind:
+		v, err := b.Int32()
+		if err != nil {
+			return nil, err
+		}
+
+		if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(v)); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return v, nil
+	case protoreflect.Int32Kind:
+		return b.Int32()
+	case protoreflect.Sint32Kind:
+		return b.Sint32()
+	case protoreflect.Uint32Kind:
+		return b.Uint32()
+	case protoreflect.Int64Kind:
+		return b.Int64()
+	case protoreflect.Sint64Kind:
+		return b.Sint64()
+	case protoreflect.Uint64Kind:
+		return b.Uint64()
+	case protoreflect.Sfixed32Kind:
+		return b.Sfixed32()
+	case protoreflect.Fixed32Kind:
+		return b.Fixed32()
+	case protoreflect.FloatKind:
+		return b.Float()
+	case protoreflect.Sfixed64Kind:
+		return b.Sfixed64()
+	case protoreflect.Fixed64Kind:
+		return b.Fixed64()
+	case protoreflect.DoubleKind:
+		return b.Double()
+	}
+
+	return nil, ErrInvalidWireType
+}
+
+// wireValue reads a value using only the wire type.
+func wireValue(b *base, wireType int) (any, error) {
+	switch wireType {
+	case WireTypeVarint:
+		return b.Varint64()
+	case WireType32bit:
+		return b.Fixed32()
+	case WireType64bit:
+		return b.Fixed64()
+	}
+
+	return nil, ErrInvalidWireType
+}
+
+// defaultValue returns the default value of a non-message field
+// as decoded by diffValues.
+func defaultValue(fd protoreflect.FieldDescriptor) any {
+	if fd.Kind() == protoreflect.EnumKind {
+		if ev := fd.DefaultEnumValue(); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(fd.Default().Enum())
+	}
+
+	return fd.Default().Interface()
+}
+
+func last(values []diffValue) *diffValue {
+	if len(values) == 0 {
+		return nil
+	}
+
+	return &values[len(values)-1]
+}
+
+func valuesEqual(a, b any) bool {
+	switch x := a.(type) {
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	case float64:
+		y, ok := b.(float64)
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	case float32:
+		y, ok := b.(float32)
+		return ok && math.Float32bits(x) == math.Float32bits(y)
+	}
+
+	return a == b
+}
+
+func formatValue(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case []byte:
+		return fmt.Sprintf("%q", x)
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,171 @@
+package pbr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiff(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+	a := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1 username: "old"
+		orders { id: 1 open: true } orders { id: 2 open: true }
+		favorite_ids: [1, 2]
+		last { id: 3 open: false }
+		by_name { key: "a" value { id: 4 open: true } }
+		by_name { key: "b" value { id: 5 open: true } }`))
+	b := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1 username: "new"
+		orders { id: 1 open: true } orders { id: 2 open: false }
+		favorite_ids: [1, 2, 3]
+		by_name { key: "b" value { id: 5 open: false } }
+		by_name { key: "c" value { id: 6 open: true } }`))
+
+	changes, err := Diff(a, b, &Schema{Descriptor: md})
+	if err != nil {
+		t.Fatalf("unable to diff: %e", err)
+	}
+
+	// embedded messages are canonicalized, the field order of the test data is not stable
+	order := &Schema{Descriptor: legacyFile.Messages().ByName("Order")}
+	var result []string
+	for _, c := range changes {
+		for _, v := range []*any{&c.Old, &c.New} {
+			if b, ok := (*v).([]byte); ok {
+				if *v, err = Canonicalize(b, order); err != nil {
+					t.Fatalf("unable to canonicalize: %e", err)
+				}
+			}
+		}
+		result = append(result, c.String())
+	}
+
+	expected := []string{
+		`~ username: "old" -> "new"`,
+		`~ orders[1].open: true -> false`,
+		`+ favorite_ids[2]: 3`,
+		`- last: "\b\x03\x10\x00"`,
+		`- by_name[a]: "\b\x04\x10\x01"`,
+		`~ by_name[b].open: true -> false`,
+		`+ by_name[c]: "\b\x06\x10\x01"`,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("incorrect changes:\n%q\nexpected:\n%q", result, expected)
+	}
+
+	changes, err = Diff(a, a, &Schema{Descriptor: md})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("message should equal itself: %v %e", changes, err)
+	}
+}
+
+func TestDiff_encoding(t *testing.T) {
+	a, err := proto.Marshal(&testmsg.Repeated{I64: []int64{1, 2, 300}, Str: []string{"a"}})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	// packed instead of unpacked and a different field order
+	var b []byte
+	b = appendBytes(b, 14, []byte("a"))
+	b = appendBytes(b, 4, []byte{0x01, 0x02, 0xac, 0x02})
+
+	for _, s := range []*Schema{nil, {Descriptor: (&testmsg.Repeated{}).ProtoReflect().Descriptor()}} {
+		changes, err := Diff(a, b, s)
+		if err != nil {
+			t.Fatalf("unable to diff: %e", err)
+		}
+
+		if len(changes) != 0 {
+			t.Errorf("encodings should be equal: %v", changes)
+		}
+	}
+}
+
+func TestDiff_heuristics(t *testing.T) {
+	a, err := proto.Marshal(&testmsg.Parent{Child: &testmsg.Child{Number: 1, Numbers: []int64{1}}, After: true})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	b, err := proto.Marshal(&testmsg.Parent{Child: &testmsg.Child{Number: 2, Numbers: []int64{1}}})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	changes, err := Diff(a, b, nil)
+	if err != nil {
+		t.Fatalf("unable to diff: %e", err)
+	}
+
+	expected := []Change{
+		{Path: "1[0].100[0]", Kind: Modified, Old: uint64(1), New: uint64(2)},
+		{Path: "32[0]", Kind: Removed, Old: uint64(1)},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("incorrect changes: %v", changes)
+	}
+}
+
+func TestDiff_defaults(t *testing.T) {
+	s := &Schema{Descriptor: (&testmsg.Customer{}).ProtoReflect().Descriptor()}
+
+	var zero []byte
+	zero = appendTag(zero, 1, WireTypeVarint)
+	zero = appendVarint(zero, 0)
+
+	changes, err := Diff(zero, nil, s)
+	if err != nil {
+		t.Fatalf("unable to diff: %e", err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("zero value should equal a missing value: %v", changes)
+	}
+
+	var one []byte
+	one = appendTag(one, 1, WireTypeVarint)
+	one = appendVarint(one, 1)
+
+	changes, err = Diff(nil, one, s)
+	if err != nil {
+		t.Fatalf("unable to diff: %e", err)
+	}
+
+	expected := []Change{{Path: "id", Kind: Modified, Old: int64(0), New: int64(1)}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("incorrect changes: %v", changes)
+	}
+}
+
+func TestDiff_mapKeys(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+	a := marshalPartial(t, newLegacy(t, "Customer", `id: 1 tags { key: 10 value: "a" }`))
+	b := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1
+		tags { key: 10 value: "b" }
+		tags { key: 2 value: "c" }
+		tags { key: -1 value: "d" }`))
+
+	changes, err := Diff(a, b, &Schema{Descriptor: md})
+	if err != nil {
+		t.Fatalf("unable to diff: %e", err)
+	}
+
+	var result []string
+	for _, c := range changes {
+		result = append(result, c.String())
+	}
+
+	expected := []string{
+		`+ tags[-1]: "d"`,
+		`+ tags[2]: "c"`,
+		`~ tags[10]: "a" -> "b"`,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("incorrect changes:\n%q\nexpected:\n%q", result, expected)
+	}
+}
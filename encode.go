@@ -0,0 +1,78 @@
+package pbr
+
+import "encoding/binary"
+
+// appendVarint appends the variable-length encoding of the value.
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(buf, byte(v))
+}
+
+// appendTag appends the field number and wire type key of a field.
+func appendTag(buf []byte, fieldNumber, wireType int) []byte {
+	return appendVarint(buf, uint64(fieldNumber)<<3|uint64(wireType&0x7))
+}
+
+// appendFixed32 appends a fixed 4 byte value.
+func appendFixed32(buf []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(buf, v)
+}
+
+// appendFixed64 appends a fixed 8 byte value.
+func appendFixed64(buf []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, v)
+}
+
+// appendBytes appends a length-delimited field with the tag.
+func appendBytes(buf []byte, fieldNumber int, v []byte) []byte {
+	buf = appendTag(buf, fieldNumber, WireTypeLengthDelimited)
+	buf = appendVarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+// sizeVarint returns the length of the variable-length encoding of the value.
+func sizeVarint(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+
+	return n
+}
+
+// zigzag64 returns the sint64 encoding of the value.
+func zigzag64(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// zigzag32 returns the sint32 encoding of the value.
+func zigzag32(v int32) uint64 {
+	return uint64(uint32(v<<1) ^ uint32(v>>31))
+}
+
+// beginLength reserves a byte for the length of the value
+// appended next and returns where the value starts.
+func beginLength(buf []byte) ([]byte, int) {
+	return append(buf, 0), len(buf) + 1
+}
+
+// endLength writes the length of the value appended since beginLength,
+// moving the value if the length needs more than one byte.
+func endLength(buf []byte, start int) []byte {
+	l := len(buf) - start
+	if l < 0x80 {
+		buf[start-1] = byte(l)
+		return buf
+	}
+
+	n := sizeVarint(uint64(l))
+	buf = append(buf, make([]byte, n-1)...)
+	copy(buf[start-1+n:], buf[start:start+l])
+	appendVarint(buf[:start-1], uint64(l))
+	return buf
+}
@@ -0,0 +1,67 @@
+package pbr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	var buf []byte
+	buf = appendTag(buf, 1, WireTypeVarint)
+	buf = appendVarint(buf, math.MaxUint64)
+	buf = appendTag(buf, 2, WireTypeVarint)
+	buf = appendVarint(buf, 9) // zig-zag encoded -5
+	buf = appendTag(buf, 3, WireType32bit)
+	buf = appendFixed32(buf, 123)
+	buf = appendTag(buf, 4, WireType64bit)
+	buf = appendFixed64(buf, 456)
+	buf = appendBytes(buf, 1000, []byte("abc"))
+
+	msg := New(buf)
+	for msg.Next() {
+		var err error
+		switch msg.FieldNumber() {
+		case 1:
+			var v uint64
+			v, err = msg.Uint64()
+			if v != math.MaxUint64 {
+				t.Errorf("incorrect value: %v", v)
+			}
+		case 2:
+			var v int64
+			v, err = msg.Sint64()
+			if v != -5 {
+				t.Errorf("incorrect value: %v", v)
+			}
+		case 3:
+			var v uint32
+			v, err = msg.Fixed32()
+			if v != 123 {
+				t.Errorf("incorrect value: %v", v)
+			}
+		case 4:
+			var v uint64
+			v, err = msg.Fixed64()
+			if v != 456 {
+				t.Errorf("incorrect value: %v", v)
+			}
+		case 1000:
+			var v string
+			v, err = msg.String()
+			if v != "abc" {
+				t.Errorf("incorrect value: %v", v)
+			}
+		default:
+			t.Errorf("unexpected field: %v", msg.FieldNumber())
+		}
+
+		if err != nil {
+			t.Fatalf("unable to read: %e", err)
+		}
+	}
+
+	if msg.Error() != nil {
+		t.Errorf("unexpected error: %e", msg.Error())
+	}
+
+}
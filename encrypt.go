@@ -0,0 +1,240 @@
+package pbr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidEnvelope is returned when an encrypted field
+// can't be parsed or decrypted.
+var ErrInvalidEnvelope = errors.New("protoscan: invalid encrypted envelope")
+
+// KeyProvider provides the AES keys for field encryption.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new values and its id.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the id to decrypt values.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider with a fixed set of keys by id.
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+// CurrentKey returns the key with the Current id.
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+// Key returns the key with the id.
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("protoscan: unknown key %q", id)
+	}
+
+	return key, nil
+}
+
+// FieldEncryptor encrypts the values of selected fields with AES-GCM
+// so the rest of the message can still be read.
+// Every occurrence of a selected field is replaced with an envelope,
+// stored as bytes in the same field number:
+//
+//	message Envelope {
+//	  string key_id = 1;
+//	  bytes nonce = 2;
+//	  bytes ciphertext = 3;
+//	}
+//
+// The plaintext is the original encoding of the occurrence, including the tag,
+// and the associated data binds it to the field path, the index of
+// the occurrence of every field on the path and the message identity.
+type FieldEncryptor struct {
+	keys KeyProvider
+	tree pathTree
+}
+
+// NewFieldEncryptor returns an encryptor for the fields at the paths.
+// Paths apply to every occurrence of repeated embedded messages.
+func NewFieldEncryptor(keys KeyProvider, paths ...Path) *FieldEncryptor {
+	return &FieldEncryptor{
+		keys: keys,
+		tree: newPathTree(paths),
+	}
+}
+
+// Encrypt returns a copy of the message with the selected fields encrypted.
+// The identity, e.g. the id of the message, must be the same to decrypt.
+func (e *FieldEncryptor) Encrypt(data, identity []byte) ([]byte, error) {
+	id, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return transformPaths(nil, data, e.tree, nil, nil, func(buf []byte, path Path, indexes []int, occurrence []byte) ([]byte, error) {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+
+		ciphertext := aead.Seal(nil, nonce, occurrence, envelopeData(path, indexes, identity))
+
+		var envelope []byte
+		envelope = appendBytes(envelope, 1, []byte(id))
+		envelope = appendBytes(envelope, 2, nonce)
+		envelope = appendBytes(envelope, 3, ciphertext)
+
+		return appendBytes(buf, path[len(path)-1], envelope), nil
+	})
+}
+
+// Decrypt returns a copy of the message with the selected fields
+// restored to their original encoding.
+func (e *FieldEncryptor) Decrypt(data, identity []byte) ([]byte, error) {
+	aeads := make(map[string]cipher.AEAD)
+	return transformPaths(nil, data, e.tree, nil, nil, func(buf []byte, path Path, indexes []int, occurrence []byte) ([]byte, error) {
+		msg := New(occurrence)
+		if !msg.Next() || msg.WireType() != WireTypeLengthDelimited {
+			return nil, fmt.Errorf("%w: field %v is not encrypted", ErrInvalidEnvelope, path)
+		}
+
+		envelope, err := msg.MessageData()
+		if err != nil {
+			return nil, err
+		}
+
+		var id string
+		var nonce, ciphertext []byte
+		m := New(envelope)
+		for m.Next() {
+			switch m.FieldNumber() {
+			case 1:
+				id, err = m.String()
+			case 2:
+				nonce, err = m.Bytes()
+			case 3:
+				ciphertext, err = m.Bytes()
+			default:
+				m.Skip()
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = m.Error()
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %v: %v", ErrInvalidEnvelope, path, err)
+		}
+
+		aead, ok := aeads[id]
+		if !ok {
+			key, err := e.keys.Key(id)
+			if err != nil {
+				return nil, err
+			}
+
+			if aead, err = newGCM(key); err != nil {
+				return nil, err
+			}
+			aeads[id] = aead
+		}
+
+		if len(nonce) != aead.NonceSize() {
+			return nil, fmt.Errorf("%w: field %v has an invalid nonce", ErrInvalidEnvelope, path)
+		}
+
+		plaintext, err := aead.Open(buf, nonce, ciphertext, envelopeData(path, indexes, identity))
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %v: %v", ErrInvalidEnvelope, path, err)
+		}
+
+		return plaintext, nil
+	})
+}
+
+// transformPaths appends the message with fn applied to every occurrence
+// of the fields in the tree. Embedded messages on a path are
+// re-encoded, everything else is copied unchanged.
+// The indexes are the occurrence of every field of the path in its message.
+func transformPaths(buf, data []byte, tree pathTree, path Path, indexes []int, fn func(buf []byte, path Path, indexes []int, occurrence []byte) ([]byte, error)) ([]byte, error) {
+	var err error
+	counts := make(map[int]int)
+	scanErr := scanFields(data, func(n int, f field) {
+		node, ok := tree[n]
+		if err != nil || !ok {
+			if err == nil {
+				buf = append(buf, data[f.start:f.end]...)
+			}
+			return
+		}
+
+		fieldPath := append(path[:len(path):len(path)], n)
+		fieldIndexes := append(indexes[:len(indexes):len(indexes)], counts[n])
+		counts[n]++
+		if len(node) == 0 {
+			buf, err = fn(buf, fieldPath, fieldIndexes, data[f.start:f.end])
+			return
+		}
+
+		if f.wireType != WireTypeLengthDelimited {
+			err = ErrInvalidWireType
+			return
+		}
+
+		var payload, sub []byte
+		if payload, err = fieldScanner(data, n, f).MessageData(); err != nil {
+			return
+		}
+
+		if sub, err = transformPaths(nil, payload, node, fieldPath, fieldIndexes, fn); err != nil {
+			return
+		}
+		buf = appendBytes(buf, n, sub)
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	return buf, err
+}
+
+// envelopeData returns the associated data of an encrypted field,
+// the indexes keep occurrences of repeated fields from being swapped.
+func envelopeData(path Path, indexes []int, identity []byte) []byte {
+	var packed []byte
+	for _, i := range indexes {
+		packed = appendVarint(packed, uint64(i))
+	}
+
+	var ad []byte
+	ad = appendBytes(ad, 1, []byte(path.String()))
+	ad = appendBytes(ad, 2, identity)
+	ad = appendBytes(ad, 3, packed)
+	return ad
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
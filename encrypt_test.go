@@ -0,0 +1,121 @@
+package pbr
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFieldEncryptor(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1, Open: true}, {Id: 2}},
+		FavoriteIds: []int64{1, 2},
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	keys := StaticKeys{
+		Current: "a",
+		Keys: map[string][]byte{
+			"a": bytes.Repeat([]byte{1}, 32),
+			"b": bytes.Repeat([]byte{2}, 16),
+		},
+	}
+	e := NewFieldEncryptor(keys, Path{2}, Path{3, 1})
+
+	encrypted, err := e.Encrypt(data, []byte("customer/1"))
+	if err != nil {
+		t.Fatalf("unable to encrypt: %e", err)
+	}
+
+	if bytes.Contains(encrypted, []byte("name")) {
+		t.Errorf("username should be encrypted")
+	}
+
+	// other fields can still be read
+	idx, err := Index(encrypted)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	id, _ := idx.Lookup(1)
+	if v, err := id.Int64(); err != nil || v != 1 {
+		t.Errorf("incorrect id: %v %e", v, err)
+	}
+
+	// rotated keys can still decrypt
+	keys.Current = "b"
+	e = NewFieldEncryptor(keys, Path{2}, Path{3, 1})
+
+	decrypted, err := e.Decrypt(encrypted, []byte("customer/1"))
+	if err != nil {
+		t.Fatalf("unable to decrypt: %e", err)
+	}
+
+	if !bytes.Equal(decrypted, data) {
+		t.Errorf("incorrect data:\n%x\n%x", decrypted, data)
+	}
+
+	if _, err := e.Decrypt(encrypted, []byte("customer/2")); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("other identity should fail: %v", err)
+	}
+
+	// an envelope moved to another field should fail
+	idx, err = Index(encrypted)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	username, _ := idx.Lookup(2)
+	envelope, err := username.Bytes()
+	if err != nil {
+		t.Fatalf("unable to read envelope: %e", err)
+	}
+
+	moved := appendBytes(nil, 5, envelope)
+	if _, err := NewFieldEncryptor(keys, Path{5}).Decrypt(moved, []byte("customer/1")); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("moved envelope should fail: %v", err)
+	}
+
+	if _, err := e.Decrypt(data, []byte("customer/1")); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("plain data should fail: %v", err)
+	}
+
+	// envelopes swapped between occurrences of a repeated field should fail
+	var orders [][]byte
+	if err := scanFields(encrypted, func(n int, f field) {
+		if n == 3 {
+			payload, _ := fieldScanner(encrypted, n, f).MessageData()
+			orders = append(orders, payload)
+		}
+	}); err != nil || len(orders) != 2 {
+		t.Fatalf("unable to read orders: %v %e", len(orders), err)
+	}
+
+	ids := make([][]byte, len(orders))
+	rests := make([][]byte, len(orders))
+	for i, order := range orders {
+		_ = scanFields(order, func(n int, f field) {
+			if n == 1 {
+				ids[i] = order[f.start:f.end]
+			} else {
+				rests[i] = append(rests[i], order[f.start:f.end]...)
+			}
+		})
+	}
+
+	var swapped []byte
+	swapped = appendBytes(swapped, 3, append(append([]byte{}, ids[1]...), rests[0]...))
+	swapped = appendBytes(swapped, 3, append(append([]byte{}, ids[0]...), rests[1]...))
+	if _, err := e.Decrypt(swapped, []byte("customer/1")); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("swapped envelopes should fail: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package pbr
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrUnknownEnumValue is returned when reading a closed enum
+// and the value is not one of the values of the enum.
+// Protobuf parsers treat these values as unknown fields.
+var ErrUnknownEnumValue = errors.New("protoscan: unknown enum value")
+
+// Enum contains the names of the values of an enum type.
+// Closed enums (proto2) only accept the declared values,
+// open enums (proto3) accept any int32 value.
+type Enum struct {
+	names  map[int32]string
+	closed bool
+}
+
+// EnumValue is a decoded enum value.
+// Name is empty if the value is not known by the enum.
+type EnumValue struct {
+	Number int32
+	Name   string
+}
+
+// NewEnum creates an enum from a table of value names.
+func NewEnum(names map[int32]string, closed bool) *Enum {
+	return &Enum{
+		names:  names,
+		closed: closed,
+	}
+}
+
+// EnumFromDescriptor creates an enum with the values of the descriptor.
+func EnumFromDescriptor(ed protoreflect.EnumDescriptor) *Enum {
+	values := ed.Values()
+	e := &Enum{
+		names:  make(map[int32]string, values.Len()),
+		closed: ed.IsClosed(),
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		// with aliases the first name is used like protobuf does.
+		if _, ok := e.names[int32(v.Number())]; !ok {
+			e.names[int32(v.Number())] = string(v.Name())
+		}
+	}
+
+	return e
+}
+
+// Closed returns true if the enum only accepts declared values.
+func (e *Enum) Closed() bool {
+	return e.closed
+}
+
+// Name returns the name of the enum value.
+func (e *Enum) Name(number int32) (string, bool) {
+	name, ok := e.names[number]
+	return name, ok
+}
+
+// Known returns true if the value is declared by the enum.
+func (v EnumValue) Known() bool {
+	return v.Name != ""
+}
+
+// Enum reads an enum value and its name.
+// For a closed enum an unknown value returns ErrUnknownEnumValue
+// together with the value, for an open enum the value is returned
+// with an empty name.
+func (b *base) Enum(e *Enum) (EnumValue, error) {
+	n, err := b.Int32()
+	if err != nil {
+		return EnumValue{}, err
+	}
+
+	return e.value(n)
+}
+
+// RepeatedEnum will append the repeated value(s) to the buffer.
+// This method supports packed or unpacked encoding.
+// Unknown values of a closed enum are appended to the unknown buffer
+// instead, as protobuf would keep them in the unknown fields.
+func (m *Message) RepeatedEnum(e *Enum, buf []EnumValue, unknown []int32) ([]EnumValue, []int32, error) {
+	if m.wireType == WireTypeVarint {
+		v, err := m.Enum(e)
+		if err == ErrUnknownEnumValue {
+			return buf, append(unknown, v.Number), nil
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return append(buf, v), unknown, nil
+	}
+
+	l, err := m.packedLength()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// if provided we append.
+	if buf == nil {
+		buf = make([]EnumValue, 0, m.count(l))
+	}
+
+	postIndex := m.Index + l
+	for m.Index < postIndex {
+		v, err := m.Enum(e)
+		if err == ErrUnknownEnumValue {
+			unknown = append(unknown, v.Number)
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		buf = append(buf, v)
+	}
+
+	return buf, unknown, nil
+}
+
+func (e *Enum) value(n int32) (EnumValue, error) {
+	name, ok := e.names[n]
+	if !ok && e.closed {
+		return EnumValue{Number: n}, ErrUnknownEnumValue
+	}
+
+	return EnumValue{Number: n, Name: name}, nil
+}
@@ -0,0 +1,130 @@
+package pbr
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestMessage_Enum(t *testing.T) {
+	t.Run("open enum", func(t *testing.T) {
+		e := EnumFromDescriptor(structpb.NullValue(0).Descriptor())
+		if e.Closed() {
+			t.Errorf("proto3 enum should be open")
+		}
+
+		msg := New([]byte{0x08, 0x00, 0x08, 0x05})
+		msg.Next()
+		v, err := msg.Enum(e)
+		if err != nil || v.Name != "NULL_VALUE" || !v.Known() {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+
+		msg.Next()
+		v, err = msg.Enum(e)
+		if err != nil || v.Number != 5 || v.Known() {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+	})
+
+	t.Run("closed enum", func(t *testing.T) {
+		e := EnumFromDescriptor(descriptorpb.FieldDescriptorProto_TYPE_INT64.Descriptor())
+		if !e.Closed() {
+			t.Errorf("proto2 enum should be closed")
+		}
+
+		msg := New([]byte{0x08, 0x03, 0x08, 0x64})
+		msg.Next()
+		v, err := msg.Enum(e)
+		if err != nil || v.Name != "TYPE_INT64" {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+
+		msg.Next()
+		v, err = msg.Enum(e)
+		if err != ErrUnknownEnumValue || v.Number != 100 {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+	})
+
+	t.Run("negative value", func(t *testing.T) {
+		e := NewEnum(map[int32]string{-1: "MINUS_ONE"}, true)
+		msg := New([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+		msg.Next()
+		v, err := msg.Enum(e)
+		if err != nil || v.Name != "MINUS_ONE" {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+	})
+}
+
+func TestMessage_RepeatedEnum(t *testing.T) {
+	names := map[int32]string{1: "ONE", 2: "TWO"}
+	data := []byte{
+		0x0a, 0x03, 0x01, 0x07, 0x02, // packed 1, 7, 2
+		0x08, 0x02, // unpacked 2
+		0x08, 0x09, // unpacked 9
+	}
+
+	t.Run("closed", func(t *testing.T) {
+		var known []EnumValue
+		var unknown []int32
+		msg := New(data)
+		for msg.Next() {
+			var err error
+			known, unknown, err = msg.RepeatedEnum(NewEnum(names, true), known, unknown)
+			if err != nil {
+				t.Fatalf("unexpected error: %e", err)
+			}
+		}
+
+		if len(known) != 3 || known[0].Name != "ONE" || known[2].Name != "TWO" {
+			t.Errorf("incorrect known values: %v", known)
+		}
+
+		if len(unknown) != 2 || unknown[0] != 7 || unknown[1] != 9 {
+			t.Errorf("incorrect unknown values: %v", unknown)
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		var values []EnumValue
+		var unknown []int32
+		msg := New(data)
+		for msg.Next() {
+			var err error
+			values, unknown, err = msg.RepeatedEnum(NewEnum(names, false), values, unknown)
+			if err != nil {
+				t.Fatalf("unexpected error: %e", err)
+			}
+		}
+
+		if len(values) != 5 || values[1].Number != 7 || values[1].Known() {
+			t.Errorf("incorrect values: %v", values)
+		}
+
+		if len(unknown) != 0 {
+			t.Errorf("open enums have no unknown values: %v", unknown)
+		}
+	})
+
+	t.Run("iterator", func(t *testing.T) {
+		msg := New(data)
+		msg.Next()
+		iter, err := msg.Iterator(nil)
+		if err != nil {
+			t.Fatalf("unable to create iterator: %e", err)
+		}
+
+		e := NewEnum(names, true)
+		iter.Skip(WireTypeVarint, 1)
+		if _, err := iter.Enum(e); err != ErrUnknownEnumValue {
+			t.Errorf("incorrect error: %v", err)
+		}
+
+		if v, err := iter.Enum(e); err != nil || v.Name != "TWO" {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+	})
+}
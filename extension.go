@@ -0,0 +1,208 @@
+package pbr
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ExtensionRegistry finds the extensions of messages by field number.
+// It is also a protoregistry.ExtensionTypeResolver, so it can be
+// used to unmarshal the extensions with the proto package.
+type ExtensionRegistry struct {
+	numbers map[protoreflect.FullName]map[protoreflect.FieldNumber]protoreflect.ExtensionType
+	names   map[protoreflect.FullName]protoreflect.ExtensionType
+}
+
+// NewExtensionRegistry returns an empty registry.
+func NewExtensionRegistry() *ExtensionRegistry {
+	return &ExtensionRegistry{
+		numbers: make(map[protoreflect.FullName]map[protoreflect.FieldNumber]protoreflect.ExtensionType),
+		names:   make(map[protoreflect.FullName]protoreflect.ExtensionType),
+	}
+}
+
+// Add registers the extension types, e.g. the generated protoimpl.ExtensionInfo
+// variables. Registering the same extension again does nothing, another
+// extension with the same extendee and number is an error.
+func (r *ExtensionRegistry) Add(xts ...protoreflect.ExtensionType) error {
+	for _, xt := range xts {
+		xd := xt.TypeDescriptor()
+		extendee := xd.ContainingMessage().FullName()
+
+		if prev, ok := r.numbers[extendee][xd.Number()]; ok {
+			if prev.TypeDescriptor().FullName() == xd.FullName() {
+				continue
+			}
+
+			return fmt.Errorf("protoscan: extension %d of %s is already registered as %s",
+				xd.Number(), extendee, prev.TypeDescriptor().FullName())
+		}
+
+		if _, ok := r.names[xd.FullName()]; ok {
+			return fmt.Errorf("protoscan: extension %s is already registered", xd.FullName())
+		}
+
+		if r.numbers[extendee] == nil {
+			r.numbers[extendee] = make(map[protoreflect.FieldNumber]protoreflect.ExtensionType)
+		}
+		r.numbers[extendee][xd.Number()] = xt
+		r.names[xd.FullName()] = xt
+	}
+
+	return nil
+}
+
+// AddDescriptors registers the extensions from their descriptors,
+// the values are dynamicpb messages when unmarshaled with the proto package.
+func (r *ExtensionRegistry) AddDescriptors(xds ...protoreflect.ExtensionDescriptor) error {
+	for _, xd := range xds {
+		if xtd, ok := xd.(protoreflect.ExtensionTypeDescriptor); ok {
+			if err := r.Add(xtd.Type()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := r.Add(dynamicpb.NewExtensionType(xd)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddFile registers all the extensions declared in the file,
+// including the ones nested in messages.
+func (r *ExtensionRegistry) AddFile(fd protoreflect.FileDescriptor) error {
+	if err := r.addExtensions(fd.Extensions()); err != nil {
+		return err
+	}
+
+	return r.addMessages(fd.Messages())
+}
+
+// AddTypes registers all the extensions in the types registry,
+// e.g. protoregistry.GlobalTypes for the generated extensions.
+func (r *ExtensionRegistry) AddTypes(types *protoregistry.Types) error {
+	var err error
+	types.RangeExtensions(func(xt protoreflect.ExtensionType) bool {
+		err = r.Add(xt)
+		return err == nil
+	})
+
+	return err
+}
+
+func (r *ExtensionRegistry) addMessages(mds protoreflect.MessageDescriptors) error {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if err := r.addExtensions(md.Extensions()); err != nil {
+			return err
+		}
+
+		if err := r.addMessages(md.Messages()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ExtensionRegistry) addExtensions(xds protoreflect.ExtensionDescriptors) error {
+	for i := 0; i < xds.Len(); i++ {
+		if err := r.AddDescriptors(xds.Get(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the extension of the message with the field number.
+func (r *ExtensionRegistry) Lookup(extendee protoreflect.FullName, fieldNumber int) (protoreflect.ExtensionTypeDescriptor, bool) {
+	xt, ok := r.numbers[extendee][protoreflect.FieldNumber(fieldNumber)]
+	if !ok {
+		return nil, false
+	}
+
+	return xt.TypeDescriptor(), true
+}
+
+// FindExtensionByName returns the extension with the full name
+// or protoregistry.NotFound.
+func (r *ExtensionRegistry) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, ok := r.names[field]; ok {
+		return xt, nil
+	}
+
+	return nil, protoregistry.NotFound
+}
+
+// FindExtensionByNumber returns the extension of the message with the field
+// number or protoregistry.NotFound.
+func (r *ExtensionRegistry) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, ok := r.numbers[message][field]; ok {
+		return xt, nil
+	}
+
+	return nil, protoregistry.NotFound
+}
+
+// Extension returns the extension descriptor of the current field
+// if it is a registered extension of the message. Its name and kind
+// tell how to read the value, e.g. with ExtensionValue.
+func (m *Message) Extension(r *ExtensionRegistry, extendee protoreflect.FullName) (protoreflect.ExtensionTypeDescriptor, bool) {
+	return r.Lookup(extendee, m.fieldNumber)
+}
+
+// ExtensionValue reads the value of the current field as the extension.
+// Embedded messages are returned as a LazyMessage. For repeated extensions
+// the value is a list with the values of this occurrence, several if packed,
+// so the values of all the occurrences need to be combined.
+func (m *Message) ExtensionValue(xd protoreflect.ExtensionDescriptor) (protoreflect.Value, error) {
+	wireType := kindWireType(xd.Kind())
+	if xd.IsList() && m.wireType == WireTypeLengthDelimited && wireType != WireTypeLengthDelimited {
+		iter, err := m.Iterator(nil)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+
+		list := &lazyList{fd: xd, values: []protoreflect.Value{}}
+		for iter.HasNext() {
+			v, err := reflectScalar(&iter.base, xd.Kind())
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			list.values = append(list.values, v)
+		}
+
+		return protoreflect.ValueOfList(list), nil
+	}
+
+	if m.wireType != wireType {
+		return protoreflect.Value{}, ErrInvalidWireType
+	}
+
+	var (
+		v   protoreflect.Value
+		err error
+	)
+	if xd.Message() != nil {
+		var data []byte
+		if data, err = m.MessageData(); err != nil {
+			return protoreflect.Value{}, err
+		}
+		v = protoreflect.ValueOfMessage(NewLazyMessage(data, xd.Message()))
+	} else if v, err = decodeReflect(m, xd); err != nil {
+		return protoreflect.Value{}, err
+	}
+
+	if xd.IsList() {
+		return protoreflect.ValueOfList(&lazyList{fd: xd, values: []protoreflect.Value{v}}), nil
+	}
+
+	return v, nil
+}
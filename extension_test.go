@@ -0,0 +1,175 @@
+package pbr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/gofeaturespb"
+)
+
+func TestExtensionRegistry(t *testing.T) {
+	r := NewExtensionRegistry()
+	if err := r.AddFile(legacyFile); err != nil {
+		t.Fatalf("unable to add file: %e", err)
+	}
+
+	for n, name := range map[int]protoreflect.FullName{
+		100: "legacy.nickname",
+		101: "legacy.scores",
+		102: "legacy.pinned",
+		103: "legacy.Order.order_count",
+	} {
+		xd, ok := r.Lookup("legacy.Customer", n)
+		if !ok || xd.FullName() != name {
+			t.Errorf("incorrect extension %d: %v", n, xd)
+		}
+	}
+
+	if _, ok := r.Lookup("legacy.Order", 100); ok {
+		t.Errorf("extension of another message should not be found")
+	}
+
+	// adding again does nothing
+	if err := r.AddFile(legacyFile); err != nil {
+		t.Errorf("adding again should not fail: %e", err)
+	}
+
+	files := &protoregistry.Files{}
+	if err := files.RegisterFile(legacyFile); err != nil {
+		t.Fatalf("unable to register: %e", err)
+	}
+
+	other, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("other.proto"),
+		Package:    proto.String("other"),
+		Syntax:     proto.String("proto2"),
+		Dependency: []string{"legacy.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("conflict"),
+			Number:   proto.Int32(100),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+			Extendee: proto.String(".legacy.Customer"),
+		}},
+	}, files)
+	if err != nil {
+		t.Fatalf("unable to create file: %e", err)
+	}
+
+	if err := r.AddFile(other); err == nil {
+		t.Errorf("conflicting extension should fail")
+	}
+}
+
+func TestExtensionRegistry_types(t *testing.T) {
+	r := NewExtensionRegistry()
+	if err := r.Add(gofeaturespb.E_Go); err != nil {
+		t.Fatalf("unable to add: %e", err)
+	}
+
+	if err := r.AddTypes(protoregistry.GlobalTypes); err != nil {
+		t.Fatalf("unable to add types: %e", err)
+	}
+
+	xt, err := r.FindExtensionByName("pb.go")
+	if err != nil || xt != gofeaturespb.E_Go {
+		t.Errorf("incorrect extension: %v %e", xt, err)
+	}
+
+	xd, ok := r.Lookup("google.protobuf.FeatureSet", 1002)
+	if !ok || xd.Kind() != protoreflect.MessageKind {
+		t.Errorf("incorrect extension: %v", xd)
+	}
+
+	if _, err := r.FindExtensionByNumber("google.protobuf.FeatureSet", 1); !errors.Is(err, protoregistry.NotFound) {
+		t.Errorf("missing extension should not be found: %v", err)
+	}
+}
+
+func TestMessage_Extension(t *testing.T) {
+	r := NewExtensionRegistry()
+	if err := r.AddFile(legacyFile); err != nil {
+		t.Fatalf("unable to add file: %e", err)
+	}
+
+	order := marshalPartial(t, newLegacy(t, "Order", `id: 1 open: true`))
+	data := marshalPartial(t, newLegacy(t, "Customer", `id: 1 username: "name"`))
+	data = appendBytes(data, 100, []byte("nick"))
+	data = appendBytes(data, 101, []byte{0x03, 0x04}) // packed -2, 2
+	data = appendTag(data, 101, WireTypeVarint)
+	data = appendVarint(data, zigzag32(-3))
+	data = appendBytes(data, 102, order)
+	data = appendTag(data, 103, WireTypeVarint)
+	data = appendVarint(data, 5)
+
+	result := make(map[protoreflect.Name]any)
+	msg := New(data)
+	for msg.Next() {
+		xd, ok := msg.Extension(r, "legacy.Customer")
+		if !ok {
+			msg.Skip()
+			continue
+		}
+
+		v, err := msg.ExtensionValue(xd)
+		if err != nil {
+			t.Fatalf("unable to read %s: %e", xd.Name(), err)
+		}
+
+		switch {
+		case xd.IsList():
+			list, _ := result[xd.Name()].([]int32)
+			for i := 0; i < v.List().Len(); i++ {
+				list = append(list, int32(v.List().Get(i).Int()))
+			}
+			result[xd.Name()] = list
+		case xd.Kind() == protoreflect.MessageKind:
+			result[xd.Name()] = v.Message().Get(legacyFile.Messages().ByName("Order").Fields().ByName("id")).Int()
+		default:
+			result[xd.Name()] = v.Interface()
+		}
+	}
+
+	if msg.Error() != nil {
+		t.Fatalf("unable to scan: %e", msg.Error())
+	}
+
+	expected := map[protoreflect.Name]any{
+		"nickname":    "nick",
+		"scores":      []int32{-2, 2, -3},
+		"pinned":      int64(1),
+		"order_count": int64(5),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("incorrect extensions: %v", result)
+	}
+
+	// the registry resolves extensions for the proto package
+	m := dynamicpb.NewMessage(legacyFile.Messages().ByName("Customer"))
+	if err := (proto.UnmarshalOptions{Resolver: r, AllowPartial: true}).Unmarshal(data, m); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	xt, err := r.FindExtensionByName("legacy.nickname")
+	if err != nil {
+		t.Fatalf("unable to find extension: %e", err)
+	}
+
+	if !m.Has(xt.TypeDescriptor()) || m.Get(xt.TypeDescriptor()).String() != "nick" {
+		t.Errorf("extension should be set: %v", m)
+	}
+
+	msg = New(appendBytes(nil, 103, []byte{0x01}))
+	msg.Next()
+	xd, _ := msg.Extension(r, "legacy.Customer")
+	if _, err := msg.ExtensionValue(xd); !errors.Is(err, ErrInvalidWireType) {
+		t.Errorf("wire type mismatch should fail: %v", err)
+	}
+}
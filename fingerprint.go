@@ -0,0 +1,183 @@
+package pbr
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"errors"
+	"hash"
+)
+
+// ErrInvalidSignature is returned when a signature doesn't match the message.
+var ErrInvalidSignature = errors.New("protoscan: invalid signature")
+
+// Fingerprint hashes the selected fields of messages so the result
+// doesn't depend on the field order or encoding choices of the producer.
+type Fingerprint struct {
+	schema *Schema
+	tree   pathTree
+}
+
+// NewFingerprint returns a fingerprint over the fields at the paths.
+// The schema is used to canonicalize the selected fields, see Canonicalize.
+// Without a schema, fields are sorted by number and varints are minimal,
+// but packed and unpacked repeated fields hash differently.
+func NewFingerprint(s *Schema, paths ...Path) *Fingerprint {
+	return &Fingerprint{
+		schema: s,
+		tree:   newPathTree(paths),
+	}
+}
+
+// Extract returns the canonical encoding of a message with only the selected
+// fields. This is what is hashed or signed. Embedded messages on a path
+// are kept, even if empty, so the number of repeated messages is covered.
+func (f *Fingerprint) Extract(data []byte) ([]byte, error) {
+	return extractPaths(data, f.tree, f.schema)
+}
+
+// Write writes the selected fields of the message to the hash.
+func (f *Fingerprint) Write(h hash.Hash, data []byte) error {
+	selected, err := f.Extract(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Write(selected)
+	return err
+}
+
+// Sum resets the hash and returns the hash of the selected fields of the message.
+func (f *Fingerprint) Sum(h hash.Hash, data []byte) ([]byte, error) {
+	h.Reset()
+	if err := f.Write(h, data); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Signer signs and verifies the selected fields of messages,
+// changes to other fields don't invalidate the signature.
+type Signer struct {
+	fingerprint *Fingerprint
+	sign        func(selected []byte) ([]byte, error)
+	verify      func(selected, sig []byte) bool
+}
+
+// NewHMACSigner returns a signer that uses HMAC with the hash and the key.
+func NewHMACSigner(f *Fingerprint, h func() hash.Hash, key []byte) *Signer {
+	sum := func(selected []byte) []byte {
+		mac := hmac.New(h, key)
+		mac.Write(selected)
+		return mac.Sum(nil)
+	}
+
+	return &Signer{
+		fingerprint: f,
+		sign: func(selected []byte) ([]byte, error) {
+			return sum(selected), nil
+		},
+		verify: func(selected, sig []byte) bool {
+			return hmac.Equal(sum(selected), sig)
+		},
+	}
+}
+
+// NewEd25519Signer returns a signer that uses the ed25519 private key.
+func NewEd25519Signer(f *Fingerprint, key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		fingerprint: f,
+		sign: func(selected []byte) ([]byte, error) {
+			return ed25519.Sign(key, selected), nil
+		},
+		verify: func(selected, sig []byte) bool {
+			return ed25519.Verify(key.Public().(ed25519.PublicKey), selected, sig)
+		},
+	}
+}
+
+// NewEd25519Verifier returns a signer that can only verify
+// signatures with the ed25519 public key.
+func NewEd25519Verifier(f *Fingerprint, key ed25519.PublicKey) *Signer {
+	return &Signer{
+		fingerprint: f,
+		sign: func([]byte) ([]byte, error) {
+			return nil, errors.New("protoscan: signing requires a private key")
+		},
+		verify: func(selected, sig []byte) bool {
+			return ed25519.Verify(key, selected, sig)
+		},
+	}
+}
+
+// Sign returns the signature of the selected fields of the message.
+func (s *Signer) Sign(data []byte) ([]byte, error) {
+	selected, err := s.fingerprint.Extract(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sign(selected)
+}
+
+// Verify returns ErrInvalidSignature if the signature
+// doesn't match the selected fields of the message.
+func (s *Signer) Verify(data, sig []byte) error {
+	selected, err := s.fingerprint.Extract(data)
+	if err != nil {
+		return err
+	}
+
+	if !s.verify(selected, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// extractPaths returns the canonical encoding of the fields in the tree.
+func extractPaths(data []byte, tree pathTree, s *Schema) ([]byte, error) {
+	var (
+		buf []byte
+		err error
+	)
+	scanErr := scanFields(data, func(n int, f field) {
+		if err != nil {
+			return
+		}
+
+		node, ok := tree[n]
+		if !ok {
+			return
+		}
+
+		if len(node) == 0 {
+			buf = append(buf, data[f.start:f.end]...)
+			return
+		}
+
+		if f.wireType != WireTypeLengthDelimited {
+			err = ErrInvalidWireType
+			return
+		}
+
+		var payload, sub []byte
+		payload, err = fieldScanner(data, n, f).MessageData()
+		if err != nil {
+			return
+		}
+
+		subSchema, _ := s.message(n)
+		sub, err = extractPaths(payload, node, subSchema)
+		buf = appendBytes(buf, n, sub)
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Canonicalize(buf, s)
+}
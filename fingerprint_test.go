@@ -0,0 +1,154 @@
+package pbr
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFingerprint(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1, Open: true}, {Id: 2}},
+		FavoriteIds: []int64{1, 300},
+	}
+	s := &Schema{Descriptor: c.ProtoReflect().Descriptor()}
+	f := NewFingerprint(s, Path{1}, Path{3, 1}, Path{4})
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	expected, err := f.Sum(sha256.New(), data)
+	if err != nil {
+		t.Fatalf("unable to hash: %e", err)
+	}
+
+	// same selected fields, different order, encoding and other fields
+	var other []byte
+	other = appendBytes(other, 3, []byte{0x10, 0x01, 0x08, 0x01})
+	other = appendTag(other, 4, WireTypeVarint)
+	other = appendVarint(other, 1)
+	other = appendTag(other, 4, WireTypeVarint)
+	other = appendVarint(other, 300)
+	other = appendBytes(other, 2, []byte("other"))
+	other = appendTag(other, 1, WireTypeVarint)
+	other = appendVarint(other, 1)
+	other = appendBytes(other, 3, []byte{0x08, 0x02, 0x18, 0x00})
+
+	result, err := f.Sum(sha256.New(), other)
+	if err != nil {
+		t.Fatalf("unable to hash: %e", err)
+	}
+
+	if !bytes.Equal(result, expected) {
+		t.Errorf("fingerprints should be equal")
+	}
+
+	c.Orders[1].Id = 3
+	changed, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	result, err = f.Sum(sha256.New(), changed)
+	if err != nil {
+		t.Fatalf("unable to hash: %e", err)
+	}
+
+	if bytes.Equal(result, expected) {
+		t.Errorf("fingerprints should not be equal")
+	}
+}
+
+func TestFingerprint_noSchema(t *testing.T) {
+	f := NewFingerprint(nil, Path{1, 100}, Path{32})
+
+	a, err := proto.Marshal(&testmsg.Parent{Child: &testmsg.Child{Number: 5, Numbers: []int64{1}}, After: true})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	var b []byte
+	b = appendTag(b, 32, WireTypeVarint)
+	b = appendVarint(b, 1)
+	b = appendBytes(b, 1, []byte{0xe0, 0x12, 0x02, 0xa0, 0x06, 0x85, 0x00}) // non-minimal number
+
+	ea, err := f.Extract(a)
+	if err != nil {
+		t.Fatalf("unable to extract: %e", err)
+	}
+
+	eb, err := f.Extract(b)
+	if err != nil {
+		t.Fatalf("unable to extract: %e", err)
+	}
+
+	if !bytes.Equal(ea, eb) {
+		t.Errorf("selected fields should be equal:\n%x\n%x", ea, eb)
+	}
+
+	if _, err := f.Extract([]byte{0x08, 0x01}); !errors.Is(err, ErrInvalidWireType) {
+		t.Errorf("scalar on the path should fail: %v", err)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	c := &testmsg.Customer{Id: 1, Username: "name"}
+	f := NewFingerprint(&Schema{Descriptor: c.ProtoReflect().Descriptor()}, Path{1})
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	c.Username = "other"
+	other, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	c.Id = 2
+	changed, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("unable to generate key: %e", err)
+	}
+
+	signers := map[string][2]*Signer{
+		"hmac":    {NewHMACSigner(f, sha256.New, []byte("key")), NewHMACSigner(f, sha256.New, []byte("key"))},
+		"ed25519": {NewEd25519Signer(f, priv), NewEd25519Verifier(f, pub)},
+	}
+
+	for name, s := range signers {
+		t.Run(name, func(t *testing.T) {
+			sig, err := s[0].Sign(data)
+			if err != nil {
+				t.Fatalf("unable to sign: %e", err)
+			}
+
+			if err := s[1].Verify(other, sig); err != nil {
+				t.Errorf("other fields should not be signed: %e", err)
+			}
+
+			if err := s[1].Verify(changed, sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("signature should be invalid: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewEd25519Verifier(f, pub).Sign(data); err == nil {
+		t.Errorf("verifier should not sign")
+	}
+}
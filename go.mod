@@ -2,5 +2,4 @@ module github.com/pchchv/pbr
 
 go 1.24.0
 
-require google.golang.org/protobuf v1.36.5 // indirect
-require github.com/pchchv/pbr v1.0.0
\ No newline at end of file
+require google.golang.org/protobuf v1.36.5
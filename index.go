@@ -0,0 +1,208 @@
+package pbr
+
+import (
+	"io"
+	"sync"
+)
+
+// FieldIndex records the location of every field in an encoded message
+// so they can be read in any order without scanning the message again.
+// The index is safe for concurrent use, the returned scanners are not.
+type FieldIndex struct {
+	data    []byte
+	fields  map[int][]field
+	numbers []int
+
+	mu   sync.Mutex
+	subs map[int]*FieldIndex
+}
+
+// field is the location of a single field occurrence in the encoded data.
+type field struct {
+	start    int // start of the tag
+	value    int // start of the value
+	end      int // end of the value
+	wireType int
+}
+
+// Index scans the encoded message once and
+// records the offsets of all the field occurrences.
+func Index(data []byte) (*FieldIndex, error) {
+	idx := &FieldIndex{
+		data:   data,
+		fields: make(map[int][]field),
+	}
+
+	err := scanFields(data, func(fieldNumber int, f field) {
+		if _, ok := idx.fields[fieldNumber]; !ok {
+			idx.numbers = append(idx.numbers, fieldNumber)
+		}
+		idx.fields[fieldNumber] = append(idx.fields[fieldNumber], f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Data returns the encoded message data that was indexed.
+func (idx *FieldIndex) Data() []byte {
+	return idx.data
+}
+
+// FieldNumbers returns the field numbers found in the message
+// in the order of their first occurrence.
+func (idx *FieldIndex) FieldNumbers() []int {
+	return idx.numbers
+}
+
+// Has returns true if the field occurs in the message at least once.
+func (idx *FieldIndex) Has(fieldNumber int) bool {
+	return len(idx.fields[fieldNumber]) > 0
+}
+
+// Count returns the number of times the field occurs in the message.
+// Note that a packed repeated field is usually a single occurrence.
+func (idx *FieldIndex) Count(fieldNumber int) int {
+	return len(idx.fields[fieldNumber])
+}
+
+// Lookup returns a scanner positioned on the value of the last
+// occurrence of the field, as required by the protobuf
+// 'last one wins' semantics for non-repeated fields.
+// The value is read using the regular accessors, e.g. Int64().
+// Returns false if the field is not in the message.
+func (idx *FieldIndex) Lookup(fieldNumber int) (*Message, bool) {
+	fields := idx.fields[fieldNumber]
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	return fieldScanner(idx.data, fieldNumber, fields[len(fields)-1]), true
+}
+
+// LookupAll returns a scanner for every occurrence of the field
+// in the order they appear in the message.
+// Useful for repeated fields which may or may not be packed.
+func (idx *FieldIndex) LookupAll(fieldNumber int) []*Message {
+	fields := idx.fields[fieldNumber]
+	if len(fields) == 0 {
+		return nil
+	}
+
+	result := make([]*Message, 0, len(fields))
+	for _, f := range fields {
+		result = append(result, fieldScanner(idx.data, fieldNumber, f))
+	}
+
+	return result
+}
+
+// Index returns the index of the embedded message
+// in the last occurrence of the field.
+// Indexes of embedded messages are built on first use and cached.
+// Returns false if the field is not in the message.
+func (idx *FieldIndex) Index(fieldNumber int) (*FieldIndex, bool, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if sub, ok := idx.subs[fieldNumber]; ok {
+		return sub, true, nil
+	}
+
+	fields := idx.fields[fieldNumber]
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+
+	data, err := fieldScanner(idx.data, fieldNumber, fields[len(fields)-1]).MessageData()
+	if err != nil {
+		return nil, false, err
+	}
+
+	sub, err := Index(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if idx.subs == nil {
+		idx.subs = make(map[int]*FieldIndex)
+	}
+	idx.subs[fieldNumber] = sub
+
+	return sub, true, nil
+}
+
+// scanFields calls fn with the location of every field in the message.
+// A group is one field that ends after its end group tag.
+func scanFields(data []byte, fn func(fieldNumber int, f field)) error {
+	msg := New(data)
+	start := 0
+	for msg.Next() {
+		n := msg.fieldNumber
+		f := field{
+			start:    start,
+			value:    msg.Index,
+			wireType: msg.wireType,
+		}
+
+		switch msg.wireType {
+		case WireTypeStartGroup:
+			skipGroup(msg, n)
+		case WireTypeEndGroup:
+			msg.err = ErrInvalidWireType
+		default:
+			msg.Skip()
+		}
+
+		if msg.err != nil {
+			break
+		}
+
+		f.end = msg.Index
+		start = msg.Index
+		fn(n, f)
+	}
+
+	return msg.err
+}
+
+// skipGroup skips the fields of a group, nested groups included,
+// until the end group tag with the field number.
+func skipGroup(msg *Message, fieldNumber int) {
+	for msg.Next() {
+		switch msg.wireType {
+		case WireTypeStartGroup:
+			skipGroup(msg, msg.fieldNumber)
+		case WireTypeEndGroup:
+			if msg.fieldNumber != fieldNumber {
+				msg.err = ErrInvalidWireType
+			}
+			return
+		default:
+			msg.Skip()
+		}
+
+		if msg.err != nil {
+			return
+		}
+	}
+
+	if msg.err == nil {
+		msg.err = io.ErrUnexpectedEOF
+	}
+}
+
+// fieldScanner returns a message that contains only the field occurrence
+// and is positioned so that the next read is the value.
+func fieldScanner(data []byte, fieldNumber int, f field) *Message {
+	return &Message{
+		base: base{
+			Data:  data[f.start:f.end],
+			Index: f.value - f.start,
+		},
+		fieldNumber: fieldNumber,
+		wireType:    f.wireType,
+	}
+}
@@ -0,0 +1,184 @@
+package pbr
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIndex(t *testing.T) {
+	parent := &testmsg.Parent{
+		Child: &testmsg.Child{
+			Number:  *proto.Int64(123),
+			Numbers: []int64{1, 2, 3},
+			Grandchild: []*testmsg.Grandchild{
+				{Number: *proto.Int64(111)},
+				{Number: *proto.Int64(222)},
+			},
+		},
+		After: *proto.Bool(true),
+	}
+
+	data, err := proto.Marshal(parent)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	idx, err := Index(data)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	if v := idx.FieldNumbers(); len(v) != 2 || v[0] != 1 || v[1] != 32 {
+		t.Errorf("incorrect field numbers: %v", v)
+	}
+
+	// read in a different order than encoded
+	msg, ok := idx.Lookup(32)
+	if !ok {
+		t.Fatalf("field 32 not found")
+	}
+
+	if v, err := msg.Bool(); err != nil || !v {
+		t.Errorf("incorrect value: %v %v", v, err)
+	}
+
+	if msg.Next() {
+		t.Errorf("scanner should only contain the field")
+	}
+
+	if _, ok := idx.Lookup(2); ok {
+		t.Errorf("field 2 should not be found")
+	}
+
+	child, ok, err := idx.Index(1)
+	if err != nil || !ok {
+		t.Fatalf("unable to index child: %v %e", ok, err)
+	}
+
+	if cached, _, _ := idx.Index(1); cached != child {
+		t.Errorf("child index should be cached")
+	}
+
+	if c := child.Count(200); c != 2 {
+		t.Errorf("incorrect count: %v", c)
+	}
+
+	var numbers []int64
+	for _, grandchild := range child.LookupAll(200) {
+		gc, err := grandchild.Message(nil)
+		if err != nil {
+			t.Fatalf("unable to read grandchild: %e", err)
+		}
+
+		for gc.Next() {
+			if gc.FieldNumber() != 1000 {
+				gc.Skip()
+				continue
+			}
+
+			v, err := gc.Int64()
+			if err != nil {
+				t.Fatalf("unable to read: %e", err)
+			}
+			numbers = append(numbers, v)
+		}
+	}
+
+	if len(numbers) != 2 || numbers[0] != 111 || numbers[1] != 222 {
+		t.Errorf("incorrect numbers: %v", numbers)
+	}
+
+	msg, _ = child.Lookup(300)
+	if v, err := msg.RepeatedInt64(nil); err != nil || len(v) != 3 {
+		t.Errorf("incorrect repeated value: %v %v", v, err)
+	}
+}
+
+func TestIndex_lastOneWins(t *testing.T) {
+	data := []byte{
+		0x08, 0x01, // field 1 = 1
+		0x10, 0x05, // field 2 = 5
+		0x08, 0x02, // field 1 = 2
+	}
+
+	idx, err := Index(data)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	msg, _ := idx.Lookup(1)
+	if v, _ := msg.Int64(); v != 2 {
+		t.Errorf("incorrect value: %v", v)
+	}
+
+	if v := len(idx.LookupAll(1)); v != 2 {
+		t.Errorf("incorrect number of occurrences: %v", v)
+	}
+}
+
+func TestIndex_errors(t *testing.T) {
+	if _, err := Index([]byte{0x0a, 0x05, 0x01}); err != io.ErrUnexpectedEOF {
+		t.Errorf("incorrect error: %v", err)
+	}
+
+	idx, err := Index([]byte{0x0a, 0x02, 0x0a, 0x05})
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	if _, _, err := idx.Index(1); err != io.ErrUnexpectedEOF {
+		t.Errorf("incorrect error: %v", err)
+	}
+
+	if sub, ok, err := idx.Index(2); ok || sub != nil || err != nil {
+		t.Errorf("missing field should not be found: %v %v %v", sub, ok, err)
+	}
+}
+
+func TestIndex_groups(t *testing.T) {
+	// 2: 7, group 5 { 1: 9, group 6 { } }
+	data := []byte{0x10, 0x07, 0x2b, 0x08, 0x09, 0x33, 0x34, 0x2c}
+
+	idx, err := Index(data)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	if idx.Has(1) || idx.Has(6) || !idx.Has(5) {
+		t.Errorf("fields of the group should not be indexed")
+	}
+
+	canonical, err := Canonicalize(data, nil)
+	if err != nil || !bytes.Equal(canonical, data) {
+		t.Errorf("group should be unchanged: %x %v", canonical, err)
+	}
+
+	if ok, err := IsCanonical(data, nil); !ok || err != nil {
+		t.Errorf("group should be canonical: %v", err)
+	}
+
+	merged, err := Merge(data, data[2:], nil)
+	if err != nil || !bytes.Equal(merged, append(slices.Clone(data), data[2:]...)) {
+		t.Errorf("groups should be kept: %x %v", merged, err)
+	}
+
+	redacted, err := NewRedactor(RedactDrop, Path{1}).Redact(data)
+	if err != nil || !bytes.Equal(redacted, data) {
+		t.Errorf("fields of the group should not be redacted: %x %v", redacted, err)
+	}
+
+	for _, invalid := range [][]byte{{0x2b, 0x08, 0x09}, {0x2b, 0x34}, {0x2c}} {
+		if _, err := Canonicalize(invalid, nil); err == nil {
+			t.Errorf("expected an error for %x", invalid)
+		}
+
+		if _, err := IsCanonical(invalid, nil); err == nil {
+			t.Errorf("expected an error for %x", invalid)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package pbr
 
+import "io"
+
 // Iterator allows for moving across a
 // packed repeated field in a 'controlled' fashion.
 type Iterator struct {
 	base
+	err         error
 	fieldNumber int
 }
 
@@ -23,6 +26,7 @@ func (m *Message) Iterator(iter *Iterator) (*Iterator, error) {
 		Data:  m.Data[m.Index : m.Index+l],
 		Index: 0,
 	}
+	iter.err = nil
 	iter.fieldNumber = m.fieldNumber
 	m.Index += l
 
@@ -33,7 +37,8 @@ func (m *Message) Iterator(iter *Iterator) (*Iterator, error) {
 // The answer depends on the type/encoding or the field:
 // double, float, fixed, sfixed are WireType32bit or WireType64bit,
 // all other types (int, uint, sint) are WireTypeVarint.
-// Any other value will cause the function to panic.
+// Any other value will return 0 and set the iterator error
+// to ErrInvalidWireType.
 func (i *Iterator) Count(wireType int) (count int) {
 	switch wireType {
 	case WireType32bit:
@@ -48,7 +53,8 @@ func (i *Iterator) Count(wireType int) (count int) {
 		}
 		return
 	default:
-		panic("invalid wire type for a packed repeated field")
+		i.err = ErrInvalidWireType
+		return 0
 	}
 }
 
@@ -58,32 +64,69 @@ func (i *Iterator) Count(wireType int) (count int) {
 // The correct wireType must be specified:
 // double, float, fixed, sfixed are WireType32bit or WireType64bit,
 // all other types (int, uint, sint) are WireTypeVarint.
-// Any other value will cause the function to panic.
-func (i *Iterator) Skip(wireType int, count int) {
+// Any other value will return ErrInvalidWireType.
+// If there are fewer than 'count' values left io.ErrUnexpectedEOF is returned.
+// The error is also kept and can be read later using Error.
+func (i *Iterator) Skip(wireType int, count int) error {
+	if i.err != nil {
+		return i.err
+	}
+
+	if count < 0 {
+		i.err = ErrInvalidLength
+		return i.err
+	}
+
 	switch wireType {
 	case WireTypeVarint:
 		for j := 0; j < count; j++ {
-			for i.Data[i.Index] >= 128 {
+			for i.Index < len(i.Data) && i.Data[i.Index] >= 128 {
 				i.Index++
 			}
+
+			if i.Index >= len(i.Data) {
+				i.err = io.ErrUnexpectedEOF
+				return i.err
+			}
 			i.Index++
 		}
 	case WireType32bit:
+		if count > (len(i.Data)-i.Index)/4 {
+			i.Index = len(i.Data)
+			i.err = io.ErrUnexpectedEOF
+			return i.err
+		}
 		i.Index += 4 * count
 	case WireType64bit:
+		if count > (len(i.Data)-i.Index)/8 {
+			i.Index = len(i.Data)
+			i.err = io.ErrUnexpectedEOF
+			return i.err
+		}
 		i.Index += 8 * count
 	default:
-		panic("invalid wire type for a packed repeated field")
+		i.err = ErrInvalidWireType
+		return i.err
 	}
+
+	return nil
 }
 
 // HasNext is used in a 'for' loop to read through all the elements.
-// Returns false when all the items have been read.
+// Returns false when all the items have been read
+// or if an error was encountered while skipping.
 // This method does NOT need to be called,
 // reading a value automatically moves in the index forward.
 // This behavior is different than Message.Next().
 func (i *Iterator) HasNext() bool {
-	return i.base.Index < len(i.base.Data)
+	return i.err == nil && i.base.Index < len(i.base.Data)
+}
+
+// Error will return any errors that were encountered
+// while counting or skipping values.
+// Errors returned by the value accessors are not kept.
+func (i *Iterator) Error() error {
+	return i.err
 }
 
 // FieldNumber returns the number for the current repeated field.
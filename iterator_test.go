@@ -710,3 +710,101 @@ func BenchmarkRepeatedInt64(b *testing.B) {
 		}
 	}
 }
+
+func TestIterator_Skip_errors(t *testing.T) {
+	message := &testmsg.Packed{
+		Flt: []float32{1, 2, 3},
+		I64: []int64{1 << 7, 1 << 15, 1 << 23},
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	t.Run("count larger than remaining", func(t *testing.T) {
+		msg := New(data)
+		for msg.Next() {
+			iter, err := msg.Iterator(nil)
+			if err != nil {
+				t.Fatalf("unable to make iterator: %e", err)
+			}
+
+			wireType := WireTypeVarint
+			if msg.FieldNumber() == 1 {
+				wireType = WireType32bit
+			}
+
+			if err := iter.Skip(wireType, 4); err != io.ErrUnexpectedEOF {
+				t.Errorf("incorrect error: %v", err)
+			}
+
+			if iter.HasNext() {
+				t.Errorf("should not have next after error")
+			}
+
+			if err := iter.Error(); err != io.ErrUnexpectedEOF {
+				t.Errorf("error should be sticky: %v", err)
+			}
+		}
+	})
+
+	t.Run("truncated varint", func(t *testing.T) {
+		iter := &Iterator{base: base{Data: []byte{0x80, 0x80}}}
+		if err := iter.Skip(WireTypeVarint, 1); err != io.ErrUnexpectedEOF {
+			t.Errorf("incorrect error: %v", err)
+		}
+	})
+
+	t.Run("overflowing count", func(t *testing.T) {
+		for _, wireType := range []int{WireType32bit, WireType64bit} {
+			iter := &Iterator{base: base{Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}}
+			if err := iter.Skip(wireType, 1<<60); err != io.ErrUnexpectedEOF {
+				t.Errorf("incorrect error: %v", err)
+			}
+
+			if iter.HasNext() || iter.Index != len(iter.Data) {
+				t.Errorf("should not have next after error: %d", iter.Index)
+			}
+		}
+	})
+
+	t.Run("negative count", func(t *testing.T) {
+		iter := &Iterator{base: base{Data: []byte{1, 2, 3, 4}}}
+		if err := iter.Skip(WireType32bit, -1); err != ErrInvalidLength {
+			t.Errorf("incorrect error: %v", err)
+		}
+	})
+
+	t.Run("invalid wire type", func(t *testing.T) {
+		iter := &Iterator{base: base{Data: []byte{1, 2, 3, 4}}}
+		if c := iter.Count(WireTypeLengthDelimited); c != 0 {
+			t.Errorf("incorrect count: %v", c)
+		}
+
+		if err := iter.Error(); err != ErrInvalidWireType {
+			t.Errorf("incorrect error: %v", err)
+		}
+
+		iter = &Iterator{base: base{Data: []byte{1, 2, 3, 4}}}
+		if err := iter.Skip(WireTypeStartGroup, 1); err != ErrInvalidWireType {
+			t.Errorf("incorrect error: %v", err)
+		}
+	})
+
+	t.Run("error is reset", func(t *testing.T) {
+		msg := New(data)
+		msg.Next()
+		iter, _ := msg.Iterator(nil)
+		iter.Skip(WireType32bit, 10)
+
+		msg.Next()
+		iter, err := msg.Iterator(iter)
+		if err != nil {
+			t.Fatalf("unable to make iterator: %e", err)
+		}
+
+		if iter.Error() != nil {
+			t.Errorf("error should be reset: %v", iter.Error())
+		}
+	})
+}
@@ -0,0 +1,844 @@
+package pbr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ErrInvalidJSON is returned when the JSON does not
+// match the proto3 JSON mapping of the message.
+var ErrInvalidJSON = errors.New("protoscan: invalid json")
+
+// JSONOptions configures the conversion from JSON to protobuf.
+type JSONOptions struct {
+	// Files is the registry used to find the types of
+	// google.protobuf.Any values, protoregistry.GlobalFiles if nil.
+	Files *protoregistry.Files
+
+	// DiscardUnknown will skip unknown fields instead of returning an error.
+	DiscardUnknown bool
+}
+
+// FromJSON reads a proto3 JSON encoded message and returns its
+// protobuf encoding, without creating the message.
+// The descriptor is used to map the JSON names to field numbers and kinds.
+func FromJSON(r io.Reader, md protoreflect.MessageDescriptor) ([]byte, error) {
+	return JSONOptions{}.FromJSON(r, md)
+}
+
+// FromJSON reads a proto3 JSON encoded message and returns
+// its protobuf encoding using the options.
+func (o JSONOptions) FromJSON(r io.Reader, md protoreflect.MessageDescriptor) ([]byte, error) {
+	d := &jsonDecoder{
+		opts: o,
+		dec:  json.NewDecoder(r),
+	}
+	d.dec.UseNumber()
+
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := d.message(nil, md, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reader must only contain the message
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, jsonError("unexpected data after %s", md.FullName())
+	}
+
+	return buf, nil
+}
+
+type jsonDecoder struct {
+	opts JSONOptions
+	dec  *json.Decoder
+}
+
+// message appends the fields of the message, tok is the first token of the value.
+func (d *jsonDecoder) message(buf []byte, md protoreflect.MessageDescriptor, tok json.Token) ([]byte, error) {
+	switch md.FullName() {
+	case "google.protobuf.Any":
+		return d.any(buf, tok)
+	case "google.protobuf.Timestamp":
+		return appendTimestamp(buf, tok)
+	case "google.protobuf.Duration":
+		return appendDuration(buf, tok)
+	case "google.protobuf.FieldMask":
+		return appendFieldMask(buf, tok)
+	case "google.protobuf.Struct":
+		return d.structValue(buf, tok)
+	case "google.protobuf.ListValue":
+		return d.listValue(buf, tok)
+	case "google.protobuf.Value":
+		return d.value(buf, tok)
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue",
+		"google.protobuf.BytesValue":
+		return d.singleNonZero(buf, md.Fields().ByNumber(1), tok)
+	}
+
+	if tok != json.Delim('{') {
+		return nil, jsonError("expected object for %s", md.FullName())
+	}
+
+	var seen, oneofs FieldSet
+	fields := md.Fields()
+	for d.dec.More() {
+		name, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByTextName(name)
+		}
+
+		if fd == nil {
+			if !d.opts.DiscardUnknown {
+				return nil, jsonError("unknown field %q in %s", name, md.FullName())
+			}
+
+			var skip json.RawMessage
+			if err := d.dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if seen.Has(int(fd.Number())) {
+			return nil, jsonError("duplicate field %q in %s", name, md.FullName())
+		}
+		seen.Add(int(fd.Number()))
+
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if od := fd.ContainingOneof(); od != nil && tok != nil {
+			if oneofs.Has(od.Index()) {
+				return nil, jsonError("multiple fields of oneof %s", od.FullName())
+			}
+			oneofs.Add(od.Index())
+		}
+
+		if buf, err = d.field(buf, fd, tok); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, d.end('}')
+}
+
+// field appends all the values of the field.
+func (d *jsonDecoder) field(buf []byte, fd protoreflect.FieldDescriptor, tok json.Token) ([]byte, error) {
+	if tok == nil {
+		// null is the default value, except for the types where it is a value.
+		if fd.Message() != nil && fd.Message().FullName() == "google.protobuf.Value" && !fd.IsList() && !fd.IsMap() {
+			return d.single(buf, fd, tok)
+		}
+
+		if fd.Enum() != nil && fd.Enum().FullName() == "google.protobuf.NullValue" && !fd.IsList() {
+			return d.singleNonZero(buf, fd, tok)
+		}
+
+		return buf, nil
+	}
+
+	switch {
+	case fd.IsMap():
+		if tok != json.Delim('{') {
+			return nil, jsonError("expected object for %s", fd.FullName())
+		}
+
+		var entry []byte
+		for d.dec.More() {
+			key, err := d.key()
+			if err != nil {
+				return nil, err
+			}
+
+			entry, err = appendMapKey(entry[:0], fd.MapKey(), key)
+			if err != nil {
+				return nil, err
+			}
+
+			tok, err := d.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			if entry, err = d.single(entry, fd.MapValue(), tok); err != nil {
+				return nil, err
+			}
+			buf = appendBytes(buf, int(fd.Number()), entry)
+		}
+
+		return buf, d.end('}')
+	case fd.IsList():
+		if tok != json.Delim('[') {
+			return nil, jsonError("expected array for %s", fd.FullName())
+		}
+
+		var packed []byte
+		for d.dec.More() {
+			tok, err := d.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			if fd.IsPacked() {
+				packed, err = appendJSONScalar(packed, fd, tok)
+			} else {
+				buf, err = d.single(buf, fd, tok)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(packed) > 0 {
+			buf = appendBytes(buf, int(fd.Number()), packed)
+		}
+
+		return buf, d.end(']')
+	}
+
+	return d.singleNonZero(buf, fd, tok)
+}
+
+// singleNonZero is like single but omits the zero value
+// of a field without presence, like proto.Marshal.
+func (d *jsonDecoder) singleNonZero(buf []byte, fd protoreflect.FieldDescriptor, tok json.Token) ([]byte, error) {
+	start := len(buf)
+	buf, err := d.single(buf, fd, tok)
+	if err != nil || fd.HasPresence() || fd.Message() != nil {
+		return buf, err
+	}
+
+	value := buf[start:]
+	_ = scanFields(value, func(_ int, f field) {
+		if isZeroValue(occurrence{data: value, field: f}) {
+			buf = buf[:start]
+		}
+	})
+
+	return buf, nil
+}
+
+// single appends one value of the field including the tag.
+func (d *jsonDecoder) single(buf []byte, fd protoreflect.FieldDescriptor, tok json.Token) ([]byte, error) {
+	n := int(fd.Number())
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		data, err := d.message(nil, fd.Message(), tok)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, n, data), nil
+	case protoreflect.GroupKind:
+		return nil, jsonError("groups are not supported: %s", fd.FullName())
+	case protoreflect.StringKind:
+		s, ok := tok.(string)
+		if !ok {
+			return nil, jsonError("expected string for %s", fd.FullName())
+		}
+		return appendBytes(buf, n, []byte(s)), nil
+	case protoreflect.BytesKind:
+		s, ok := tok.(string)
+		if !ok {
+			return nil, jsonError("expected base64 string for %s", fd.FullName())
+		}
+
+		v, err := decodeBase64(s)
+		if err != nil {
+			return nil, jsonError("invalid base64 for %s", fd.FullName())
+		}
+		return appendBytes(buf, n, v), nil
+	}
+
+	buf = appendTag(buf, n, kindWireType(fd.Kind()))
+	return appendJSONScalar(buf, fd, tok)
+}
+
+// any appends a google.protobuf.Any. The fields of the value
+// are in the same object as the '@type' so the object is buffered.
+func (d *jsonDecoder) any(buf []byte, tok json.Token) ([]byte, error) {
+	if tok != json.Delim('{') {
+		return nil, jsonError("expected object for google.protobuf.Any")
+	}
+
+	var (
+		typeURL string
+		fields  []string
+		values  []json.RawMessage
+	)
+
+	for d.dec.More() {
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		var raw json.RawMessage
+		if err := d.dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		if key == "@type" {
+			if err := json.Unmarshal(raw, &typeURL); err != nil {
+				return nil, jsonError("invalid @type")
+			}
+			continue
+		}
+
+		fields = append(fields, key)
+		values = append(values, raw)
+	}
+
+	if err := d.end('}'); err != nil {
+		return nil, err
+	}
+
+	if typeURL == "" {
+		if len(fields) > 0 {
+			return nil, jsonError("missing @type in google.protobuf.Any")
+		}
+		return buf, nil
+	}
+
+	files := d.opts.Files
+	if files == nil {
+		files = protoregistry.GlobalFiles
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(anyMessageName(typeURL)))
+	if err != nil {
+		return nil, ErrUnknownAnyType
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, ErrUnknownAnyType
+	}
+
+	// well-known types with a special mapping are in the 'value' field
+	var object bytes.Buffer
+	if isWellKnownJSON(md.FullName()) {
+		if len(fields) != 1 || fields[0] != "value" {
+			return nil, jsonError("expected value for %s in google.protobuf.Any", md.FullName())
+		}
+		object.Write(values[0])
+	} else {
+		object.WriteByte('{')
+		for i, f := range fields {
+			if i > 0 {
+				object.WriteByte(',')
+			}
+
+			key, _ := json.Marshal(f)
+			object.Write(key)
+			object.WriteByte(':')
+			object.Write(values[i])
+		}
+		object.WriteByte('}')
+	}
+
+	value, err := d.opts.FromJSON(&object, md)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = appendBytes(buf, 1, []byte(typeURL))
+	if len(value) == 0 {
+		return buf, nil
+	}
+
+	return appendBytes(buf, 2, value), nil
+}
+
+// structValue appends the fields of a google.protobuf.Struct.
+func (d *jsonDecoder) structValue(buf []byte, tok json.Token) ([]byte, error) {
+	if tok != json.Delim('{') {
+		return nil, jsonError("expected object for google.protobuf.Struct")
+	}
+
+	var entry []byte
+	for d.dec.More() {
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := d.value(nil, tok)
+		if err != nil {
+			return nil, err
+		}
+
+		entry = appendBytes(entry[:0], 1, []byte(key))
+		entry = appendBytes(entry, 2, value)
+		buf = appendBytes(buf, 1, entry)
+	}
+
+	return buf, d.end('}')
+}
+
+// listValue appends the fields of a google.protobuf.ListValue.
+func (d *jsonDecoder) listValue(buf []byte, tok json.Token) ([]byte, error) {
+	if tok != json.Delim('[') {
+		return nil, jsonError("expected array for google.protobuf.ListValue")
+	}
+
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := d.value(nil, tok)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendBytes(buf, 1, value)
+	}
+
+	return buf, d.end(']')
+}
+
+// value appends the fields of a google.protobuf.Value.
+func (d *jsonDecoder) value(buf []byte, tok json.Token) ([]byte, error) {
+	switch v := tok.(type) {
+	case nil:
+		buf = appendTag(buf, 1, WireTypeVarint)
+		return appendVarint(buf, 0), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, jsonError("invalid number %s", v)
+		}
+		buf = appendTag(buf, 2, WireType64bit)
+		return appendFixed64(buf, math.Float64bits(f)), nil
+	case string:
+		return appendBytes(buf, 3, []byte(v)), nil
+	case bool:
+		buf = appendTag(buf, 4, WireTypeVarint)
+		if v {
+			return appendVarint(buf, 1), nil
+		}
+		return appendVarint(buf, 0), nil
+	case json.Delim:
+		var (
+			data []byte
+			err  error
+		)
+
+		if v == '{' {
+			data, err = d.structValue(nil, tok)
+			buf = appendBytes(buf, 5, data)
+		} else {
+			data, err = d.listValue(nil, tok)
+			buf = appendBytes(buf, 6, data)
+		}
+
+		return buf, err
+	}
+
+	return nil, jsonError("invalid value %v", tok)
+}
+
+// key reads the name of the next object member.
+func (d *jsonDecoder) key() (string, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return "", err
+	}
+
+	key, ok := tok.(string)
+	if !ok {
+		return "", jsonError("expected object key")
+	}
+
+	return key, nil
+}
+
+// end reads the closing delimiter of an object or array.
+func (d *jsonDecoder) end(delim json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return jsonError("expected %v", delim)
+	}
+
+	return nil
+}
+
+// appendJSONScalar appends a scalar value that can be packed, without the tag.
+func appendJSONScalar(buf []byte, fd protoreflect.FieldDescriptor, tok json.Token) ([]byte, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, ok := tok.(bool)
+		if !ok {
+			return nil, jsonError("expected bool for %s", fd.FullName())
+		}
+
+		if v {
+			return appendVarint(buf, 1), nil
+		}
+		return appendVarint(buf, 0), nil
+	case protoreflect.EnumKind:
+		if s, ok := tok.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return nil, jsonError("unknown enum value %q for %s", s, fd.FullName())
+			}
+			return appendVarint(buf, uint64(int64(ev.Number()))), nil
+		}
+
+		if tok == nil {
+			return appendVarint(buf, 0), nil
+		}
+
+		v, err := jsonInt(tok, 32)
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, uint64(v)), nil
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		v, err := jsonInt(tok, kindBits(fd.Kind()))
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, uint64(v)), nil
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		v, err := jsonInt(tok, kindBits(fd.Kind()))
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, uint64((v<<1)^(v>>63))), nil
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		v, err := jsonUint(tok, kindBits(fd.Kind()))
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(buf, v), nil
+	case protoreflect.Sfixed32Kind:
+		v, err := jsonInt(tok, 32)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed32(buf, uint32(v)), nil
+	case protoreflect.Fixed32Kind:
+		v, err := jsonUint(tok, 32)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed32(buf, uint32(v)), nil
+	case protoreflect.Sfixed64Kind:
+		v, err := jsonInt(tok, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed64(buf, uint64(v)), nil
+	case protoreflect.Fixed64Kind:
+		v, err := jsonUint(tok, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed64(buf, v), nil
+	case protoreflect.FloatKind:
+		v, err := jsonFloat(tok, 32)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed32(buf, math.Float32bits(float32(v))), nil
+	case protoreflect.DoubleKind:
+		v, err := jsonFloat(tok, 64)
+		if err != nil {
+			return nil, err
+		}
+		return appendFixed64(buf, math.Float64bits(v)), nil
+	}
+
+	return nil, jsonError("invalid value for %s", fd.FullName())
+}
+
+// appendMapKey appends the key field of a map entry from the JSON object key.
+func appendMapKey(buf []byte, fd protoreflect.FieldDescriptor, key string) ([]byte, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return appendBytes(buf, 1, []byte(key)), nil
+	}
+
+	var tok json.Token = json.Number(key)
+	if fd.Kind() == protoreflect.BoolKind {
+		switch key {
+		case "true":
+			tok = true
+		case "false":
+			tok = false
+		default:
+			return nil, jsonError("invalid map key %q", key)
+		}
+	}
+
+	buf = appendTag(buf, 1, kindWireType(fd.Kind()))
+	return appendJSONScalar(buf, fd, tok)
+}
+
+func kindBits(k protoreflect.Kind) int {
+	switch k {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind:
+		return 32
+	}
+
+	return 64
+}
+
+// jsonInt returns the integer of a JSON number or string.
+func jsonInt(tok json.Token, bits int) (int64, error) {
+	s, err := jsonNumber(tok)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(s, 10, bits)
+	if err == nil {
+		return v, nil
+	}
+
+	// exponents are allowed if the value is an integer
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != math.Trunc(f) || f < -math.Ldexp(1, bits-1) || f >= math.Ldexp(1, bits-1) {
+		return 0, jsonError("invalid integer %s", s)
+	}
+
+	return int64(f), nil
+}
+
+// jsonUint returns the unsigned integer of a JSON number or string.
+func jsonUint(tok json.Token, bits int) (uint64, error) {
+	s, err := jsonNumber(tok)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err == nil {
+		return v, nil
+	}
+
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != math.Trunc(f) || f < 0 || f >= math.Ldexp(1, bits) {
+		return 0, jsonError("invalid integer %s", s)
+	}
+
+	return uint64(f), nil
+}
+
+// jsonFloat returns the float of a JSON number or string,
+// including the special values "NaN", "Infinity" and "-Infinity".
+func jsonFloat(tok json.Token, bits int) (float64, error) {
+	switch tok {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+
+	s, err := jsonNumber(tok)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, jsonError("invalid number %s", s)
+	}
+
+	return v, nil
+}
+
+func jsonNumber(tok json.Token) (string, error) {
+	switch v := tok.(type) {
+	case json.Number:
+		return string(v), nil
+	case string:
+		return v, nil
+	}
+
+	return "", jsonError("expected number, got %v", tok)
+}
+
+// appendTimestamp appends the fields of a google.protobuf.Timestamp
+// from an RFC 3339 string.
+func appendTimestamp(buf []byte, tok json.Token) ([]byte, error) {
+	s, ok := tok.(string)
+	if !ok {
+		return nil, jsonError("expected string for google.protobuf.Timestamp")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, jsonError("invalid timestamp %q", s)
+	}
+
+	secs := t.Unix()
+	if secs < minTimestampSeconds || secs > maxTimestampSeconds {
+		return nil, ErrInvalidTimestamp
+	}
+
+	return appendSecondsNanos(buf, secs, int32(t.Nanosecond())), nil
+}
+
+// appendDuration appends the fields of a google.protobuf.Duration
+// from a string like "-1.5s".
+func appendDuration(buf []byte, tok json.Token) ([]byte, error) {
+	s, ok := tok.(string)
+	if !ok || !strings.HasSuffix(s, "s") {
+		return nil, jsonError("invalid duration %v", tok)
+	}
+
+	s = strings.TrimSuffix(s, "s")
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > 9 || whole[0] == '+' || whole[0] == '-' {
+		return nil, jsonError("invalid duration %v", tok)
+	}
+
+	secs, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return nil, jsonError("invalid duration %v", tok)
+	}
+
+	var nanos int64
+	if frac != "" {
+		if nanos, err = strconv.ParseInt(frac, 10, 64); err != nil || frac[0] == '+' || frac[0] == '-' {
+			return nil, jsonError("invalid duration %v", tok)
+		}
+
+		for i := len(frac); i < 9; i++ {
+			nanos *= 10
+		}
+	}
+
+	if secs > maxDurationSeconds {
+		return nil, ErrInvalidDuration
+	}
+
+	if neg {
+		secs, nanos = -secs, -nanos
+	}
+
+	return appendSecondsNanos(buf, secs, int32(nanos)), nil
+}
+
+func appendSecondsNanos(buf []byte, secs int64, nanos int32) []byte {
+	if secs != 0 {
+		buf = appendTag(buf, 1, WireTypeVarint)
+		buf = appendVarint(buf, uint64(secs))
+	}
+
+	if nanos != 0 {
+		buf = appendTag(buf, 2, WireTypeVarint)
+		buf = appendVarint(buf, uint64(int64(nanos)))
+	}
+
+	return buf
+}
+
+// appendFieldMask appends the paths of a google.protobuf.FieldMask from
+// a comma separated list of lowerCamelCase paths.
+func appendFieldMask(buf []byte, tok json.Token) ([]byte, error) {
+	s, ok := tok.(string)
+	if !ok {
+		return nil, jsonError("expected string for google.protobuf.FieldMask")
+	}
+
+	if s == "" {
+		return buf, nil
+	}
+
+	for _, path := range strings.Split(s, ",") {
+		var snake []byte
+		for i := 0; i < len(path); i++ {
+			c := path[i]
+			if c == '_' {
+				return nil, jsonError("invalid field mask path %q", path)
+			}
+
+			if 'A' <= c && c <= 'Z' {
+				snake = append(snake, '_', c+'a'-'A')
+				continue
+			}
+			snake = append(snake, c)
+		}
+
+		buf = appendBytes(buf, 1, snake)
+	}
+
+	return buf, nil
+}
+
+func isWellKnownJSON(name protoreflect.FullName) bool {
+	switch name {
+	case "google.protobuf.Any", "google.protobuf.Timestamp", "google.protobuf.Duration",
+		"google.protobuf.FieldMask", "google.protobuf.Struct", "google.protobuf.ListValue",
+		"google.protobuf.Value", "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue",
+		"google.protobuf.BytesValue":
+		return true
+	}
+
+	return false
+}
+
+// decodeBase64 decodes standard or URL-safe base64 with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+
+	return enc.DecodeString(s)
+}
+
+func jsonError(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidJSON}, args...)...)
+}
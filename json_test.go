@@ -0,0 +1,259 @@
+package pbr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// wellKnownMessage is a proto3 message with well-known types and maps.
+var wellKnownMessage = func() protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+
+		return fd
+	}
+
+	const message = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	names := field("names", 11, message, ".google.protobuf.StringValue")
+	names.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	counts := field("counts", 8, message, ".wkt.All.CountsEntry")
+	counts.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	children := field("children", 9, message, ".wkt.All.ChildrenEntry")
+	children.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("wkt.proto"),
+		Package: proto.String("wkt"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"google/protobuf/any.proto",
+			"google/protobuf/duration.proto",
+			"google/protobuf/field_mask.proto",
+			"google/protobuf/struct.proto",
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/wrappers.proto",
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("All"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("ts", 1, message, ".google.protobuf.Timestamp"),
+				field("dur", 2, message, ".google.protobuf.Duration"),
+				field("i64", 3, message, ".google.protobuf.Int64Value"),
+				field("st", 4, message, ".google.protobuf.Struct"),
+				field("val", 5, message, ".google.protobuf.Value"),
+				field("any", 6, message, ".google.protobuf.Any"),
+				field("mask", 7, message, ".google.protobuf.FieldMask"),
+				counts,
+				children,
+				field("null_value", 10, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".google.protobuf.NullValue"),
+				names,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("CountsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+						field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				},
+				{
+					Name: proto.String("ChildrenEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+						field("value", 2, message, ".wkt.All"),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+
+	return fd.Messages().Get(0)
+}()
+
+func TestFromJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		md   protoreflect.MessageDescriptor
+		json string
+	}{
+		{
+			name: "scalars",
+			md:   (&testmsg.Scalar{}).ProtoReflect().Descriptor(),
+			json: `{"flt": 1.5, "dbl": "NaN", "i32": -5, "i64": "-9007199254740993", "u32": 4294967295,
+				"u64": "18446744073709551615", "s32": -3, "s64": "1e3", "f32": 7, "f64": "8",
+				"sf32": -9, "sf64": -10, "bool": true, "str": "héllo", "byte": "AQL_", "after": null}`,
+		},
+		{
+			name: "packed",
+			md:   (&testmsg.Packed{}).ProtoReflect().Descriptor(),
+			json: `{"flt": [1, "Infinity"], "i64": [1, -2, "3"], "s32": [-1, 1], "bool": [true, false],
+				"str": ["a", "b"], "byte": ["AQI=", ""], "sf64": []}`,
+		},
+		{
+			name: "nested",
+			md:   (&testmsg.Customer{}).ProtoReflect().Descriptor(),
+			json: `{"id": 1, "username": "name", "orders": [{"id": 2, "open": true, "items": [{"id": 3}]}, {}],
+				"favorite_ids": [4, 5]}`,
+		},
+		{
+			name: "well-known types",
+			md:   wellKnownMessage,
+			json: `{
+				"ts": "2024-02-29T12:30:45.123Z",
+				"dur": "-1.500s",
+				"i64": "123",
+				"st": {"a": [1, "b", null, {"c": true}]},
+				"val": null,
+				"any": {"@type": "type.googleapis.com/testmsg.Item", "id": "5"},
+				"mask": "id,orders.itemCount",
+				"counts": {"a": 1, "b": "2"},
+				"children": {"true": {"any": {"value": "1s", "@type": "type.googleapis.com/google.protobuf.Duration"}}},
+				"nullValue": null,
+				"names": ["x", ""]
+			}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expected := dynamicpb.NewMessage(c.md)
+			if err := protojson.Unmarshal([]byte(c.json), expected); err != nil {
+				t.Fatalf("unable to unmarshal json: %e", err)
+			}
+
+			data, err := FromJSON(strings.NewReader(c.json), c.md)
+			if err != nil {
+				t.Fatalf("unable to convert: %v", err)
+			}
+
+			result := dynamicpb.NewMessage(c.md)
+			if err := proto.Unmarshal(data, result); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+
+			if !proto.Equal(result, expected) && c.name != "scalars" {
+				t.Errorf("incorrect result:\n%v\n%v", result, expected)
+			}
+
+			// NaN is never equal, compare the encodings instead.
+			if c.name == "scalars" {
+				a, _ := proto.MarshalOptions{Deterministic: true}.Marshal(result)
+				b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(expected)
+				if string(a) != string(b) {
+					t.Errorf("incorrect result:\n%v\n%v", result, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestFromJSON_defaults(t *testing.T) {
+	cases := []struct {
+		md   protoreflect.MessageDescriptor
+		json string
+	}{
+		{md: (&testmsg.Customer{}).ProtoReflect().Descriptor(), json: `{"username": "", "id": "0"}`},
+		{md: (&testmsg.Scalar{}).ProtoReflect().Descriptor(), json: `{"i64": "-0", "dbl": -0, "bool": false, "byte": ""}`},
+		{md: wellKnownMessage, json: `{"null_value": null, "any": {"@type": "type.googleapis.com/google.protobuf.Int64Value", "value": "0"}}`},
+	}
+
+	for _, c := range cases {
+		expected := dynamicpb.NewMessage(c.md)
+		if err := protojson.Unmarshal([]byte(c.json), expected); err != nil {
+			t.Fatalf("unable to unmarshal json: %e", err)
+		}
+
+		encoded, err := proto.Marshal(expected)
+		if err != nil {
+			t.Fatalf("unable to marshal: %e", err)
+		}
+
+		data, err := FromJSON(strings.NewReader(c.json), c.md)
+		if err != nil {
+			t.Fatalf("unable to convert: %v", err)
+		}
+
+		if !bytes.Equal(data, encoded) {
+			t.Errorf("incorrect encoding of %s:\n%x\n%x", c.json, data, encoded)
+		}
+	}
+}
+
+func TestFromJSON_errors(t *testing.T) {
+	md := (&testmsg.Customer{}).ProtoReflect().Descriptor()
+	cases := []string{
+		`[]`,
+		`{"unknown": 1}`,
+		`{"id": "abc"}`,
+		`{"id": 1.5}`,
+		`{"username": 1}`,
+		`{"orders": {}}`,
+		`{"favoriteIds": [true]}`,
+		`{"id": 1, "id": 2}`,
+		`{"favoriteIds": [1], "favorite_ids": [2]}`,
+		`{"id": "1"} garbage`,
+		`{"id": "1"} {}`,
+	}
+
+	for _, c := range cases {
+		if _, err := FromJSON(strings.NewReader(c), md); !errors.Is(err, ErrInvalidJSON) {
+			t.Errorf("incorrect error for %s: %v", c, err)
+		}
+	}
+
+	if _, err := FromJSON(strings.NewReader(`{"id": `), md); err == nil {
+		t.Errorf("expected an error for truncated json")
+	}
+
+	data, err := JSONOptions{DiscardUnknown: true}.FromJSON(strings.NewReader(`{"unknown": {"a": [1]}, "id": 5}`), md)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := &testmsg.Customer{}
+	if err := proto.Unmarshal(data, c); err != nil || c.Id != 5 {
+		t.Errorf("incorrect result: %v %e", c, err)
+	}
+
+	for _, c := range []string{`{"dur": "--1s"}`, `{"dur": "-+1s"}`, `{"dur": "1.-5s"}`} {
+		if _, err := FromJSON(strings.NewReader(c), wellKnownMessage); !errors.Is(err, ErrInvalidJSON) {
+			t.Errorf("incorrect error for %s: %v", c, err)
+		}
+	}
+
+	any := `{"any": {"@type": "type.googleapis.com/unknown.Type"}}`
+	if _, err := FromJSON(strings.NewReader(any), wellKnownMessage); err != ErrUnknownAnyType {
+		t.Errorf("incorrect error: %v", err)
+	}
+}
@@ -0,0 +1,639 @@
+package pbr
+
+import (
+	"math"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// LazyMessage is a protoreflect.Message that decodes fields from the encoded
+// message on first access, using a FieldIndex built on first use.
+// Embedded messages are lazy messages themselves. The first change, e.g. Set,
+// decodes the whole message into a dynamicpb.Message which is used from then on.
+// The lists, maps and messages returned by Get are read-only,
+// use Mutable to change them.
+// Fields with an invalid encoding read as not set, see Error.
+// Reads are safe for concurrent use, changes are not.
+type LazyMessage struct {
+	md       protoreflect.MessageDescriptor
+	data     [][]byte // chunks of the message, merged
+	valid    bool
+	readOnly bool // returned by Get of the parent
+
+	mu      sync.Mutex
+	idx     []*FieldIndex
+	values  map[protoreflect.FieldNumber]protoreflect.Value
+	err     error
+	mutable *dynamicpb.Message
+}
+
+// NewLazyMessage returns a lazy message of the type reading from the data.
+func NewLazyMessage(data []byte, md protoreflect.MessageDescriptor) *LazyMessage {
+	return newLazyMessage(md, [][]byte{data}, true)
+}
+
+func newLazyMessage(md protoreflect.MessageDescriptor, data [][]byte, valid bool) *LazyMessage {
+	return &LazyMessage{
+		md:    md,
+		data:  data,
+		valid: valid,
+	}
+}
+
+// readOnlyLazyMessage returns an embedded message of the data, changes to it
+// would be lost when the parent is decoded so they are not allowed.
+func readOnlyLazyMessage(md protoreflect.MessageDescriptor, data ...[]byte) *LazyMessage {
+	m := newLazyMessage(md, data, true)
+	m.readOnly = true
+	return m
+}
+
+// Error returns the first decoding error of the message, not of embedded messages.
+func (m *LazyMessage) Error() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.index()
+	return m.err
+}
+
+// Mutated returns true if the message has been changed and decoded.
+func (m *LazyMessage) Mutated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mutable != nil
+}
+
+// ProtoReflect returns the message itself, so it is also a proto.Message.
+func (m *LazyMessage) ProtoReflect() protoreflect.Message {
+	return m
+}
+
+// Descriptor returns the message descriptor.
+func (m *LazyMessage) Descriptor() protoreflect.MessageDescriptor {
+	return m.md
+}
+
+// Type returns the message type, new messages are lazy messages without data.
+func (m *LazyMessage) Type() protoreflect.MessageType {
+	return lazyType{m.md}
+}
+
+// New returns a new empty message of the same type.
+func (m *LazyMessage) New() protoreflect.Message {
+	return NewLazyMessage(nil, m.md)
+}
+
+// Interface returns the message itself.
+func (m *LazyMessage) Interface() protoreflect.ProtoMessage {
+	return m
+}
+
+// Range calls f for every populated field in the order of the first occurrence.
+func (m *LazyMessage) Range(f func(protoreflect.FieldDescriptor, protoreflect.Value) bool) {
+	if mutable := m.mutated(); mutable != nil {
+		mutable.Range(f)
+		return
+	}
+
+	fields := m.md.Fields()
+	for _, n := range m.fieldNumbers() {
+		fd := fields.ByNumber(protoreflect.FieldNumber(n))
+		if fd == nil || !m.Has(fd) {
+			continue
+		}
+
+		if !f(fd, m.Get(fd)) {
+			return
+		}
+	}
+}
+
+// Has reports whether the field is populated.
+func (m *LazyMessage) Has(fd protoreflect.FieldDescriptor) bool {
+	if mutable := m.mutated(); mutable != nil {
+		return mutable.Has(fd)
+	}
+
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		return m.WhichOneof(od) == fd
+	}
+
+	if len(m.occurrences(fd)) == 0 {
+		return false
+	}
+
+	v := m.Get(fd)
+	switch {
+	case fd.IsList():
+		return v.List().Len() > 0
+	case fd.IsMap():
+		return v.Map().Len() > 0
+	case fd.Message() != nil || fd.HasPresence():
+		return true
+	}
+
+	return !isZeroReflect(v)
+}
+
+// Get returns the value of the field, or the default value if it is not populated.
+// Lists, maps and messages are read-only.
+func (m *LazyMessage) Get(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	if mutable := m.mutated(); mutable != nil {
+		return mutable.Get(fd)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.values[fd.Number()]; ok {
+		return v
+	}
+
+	v, err := m.decode(fd)
+	if err != nil {
+		if m.err == nil {
+			m.err = err
+		}
+		v = emptyValue(fd)
+	}
+
+	if m.values == nil {
+		m.values = make(map[protoreflect.FieldNumber]protoreflect.Value)
+	}
+	m.values[fd.Number()] = v
+
+	return v
+}
+
+// WhichOneof returns the field of the oneof that occurs last, or nil.
+func (m *LazyMessage) WhichOneof(od protoreflect.OneofDescriptor) protoreflect.FieldDescriptor {
+	if mutable := m.mutated(); mutable != nil {
+		return mutable.WhichOneof(od)
+	}
+
+	var (
+		result protoreflect.FieldDescriptor
+		last   = -1
+		chunk  = -1
+	)
+	fields := od.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		for _, o := range m.occurrences(fd) {
+			if o.chunk > chunk || (o.chunk == chunk && o.start > last) {
+				result, chunk, last = fd, o.chunk, o.start
+			}
+		}
+	}
+
+	return result
+}
+
+// GetUnknown returns the fields that are not in the message descriptor.
+func (m *LazyMessage) GetUnknown() protoreflect.RawFields {
+	if mutable := m.mutated(); mutable != nil {
+		return mutable.GetUnknown()
+	}
+
+	var raw protoreflect.RawFields
+	fields := m.md.Fields()
+	for _, data := range m.data {
+		_ = scanFields(data, func(n int, f field) {
+			if fields.ByNumber(protoreflect.FieldNumber(n)) == nil {
+				raw = append(raw, data[f.start:f.end]...)
+			}
+		})
+	}
+
+	return raw
+}
+
+// IsValid returns false for the read-only empty message
+// returned by Get for an embedded message that is not set.
+func (m *LazyMessage) IsValid() bool {
+	return m.valid
+}
+
+// ProtoMethods returns nil, the reflection methods are used.
+func (m *LazyMessage) ProtoMethods() *protoiface.Methods {
+	return nil
+}
+
+// Set decodes the message, if not done yet, and sets the field.
+func (m *LazyMessage) Set(fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	m.materialize().Set(fd, v)
+}
+
+// Clear decodes the message, if not done yet, and clears the field.
+func (m *LazyMessage) Clear(fd protoreflect.FieldDescriptor) {
+	m.materialize().Clear(fd)
+}
+
+// Mutable decodes the message, if not done yet,
+// and returns a mutable reference to the composite field.
+// Changes to it are kept, unlike the read-only values returned by Get.
+func (m *LazyMessage) Mutable(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	return m.materialize().Mutable(fd)
+}
+
+// NewField returns a new value assignable to the field.
+func (m *LazyMessage) NewField(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	return dynamicpb.NewMessage(m.md).NewField(fd)
+}
+
+// SetUnknown decodes the message, if not done yet, and sets the unknown fields.
+func (m *LazyMessage) SetUnknown(raw protoreflect.RawFields) {
+	m.materialize().SetUnknown(raw)
+}
+
+func (m *LazyMessage) mutated() *dynamicpb.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mutable
+}
+
+// materialize decodes the message into a dynamic message.
+func (m *LazyMessage) materialize() *dynamicpb.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mutable != nil {
+		return m.mutable
+	}
+
+	if !m.valid {
+		panic("protoscan: change to an invalid message")
+	}
+
+	if m.readOnly {
+		panic("protoscan: change to a read-only message, use Mutable of the parent")
+	}
+
+	mutable := dynamicpb.NewMessage(m.md)
+	opts := proto.UnmarshalOptions{AllowPartial: true, Merge: true}
+	for _, data := range m.data {
+		if err := opts.Unmarshal(data, mutable); err != nil && m.err == nil {
+			m.err = err
+		}
+	}
+
+	m.mutable = mutable
+	m.values = nil
+	return mutable
+}
+
+// index builds the indexes of the chunks, must be called with the lock held.
+func (m *LazyMessage) index() []*FieldIndex {
+	if m.idx != nil || m.err != nil {
+		return m.idx
+	}
+
+	idx := make([]*FieldIndex, 0, len(m.data))
+	for _, data := range m.data {
+		i, err := Index(data)
+		if err != nil {
+			m.err = err
+			return nil
+		}
+		idx = append(idx, i)
+	}
+
+	m.idx = idx
+	return idx
+}
+
+// lazyOccurrence is a field occurrence in one of the chunks.
+type lazyOccurrence struct {
+	chunk int
+	field
+	data []byte
+}
+
+// occurrences returns the occurrences of the field with a valid wire type.
+func (m *LazyMessage) occurrences(fd protoreflect.FieldDescriptor) []lazyOccurrence {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.occurrencesLocked(fd)
+}
+
+func (m *LazyMessage) occurrencesLocked(fd protoreflect.FieldDescriptor) []lazyOccurrence {
+	wireType := kindWireType(fd.Kind())
+	packable := fd.IsList() && wireType != WireTypeLengthDelimited
+
+	var result []lazyOccurrence
+	for i, idx := range m.index() {
+		for _, f := range idx.fields[int(fd.Number())] {
+			if f.wireType == wireType || (packable && f.wireType == WireTypeLengthDelimited) {
+				result = append(result, lazyOccurrence{chunk: i, field: f, data: idx.data})
+			}
+		}
+	}
+
+	return result
+}
+
+func (m *LazyMessage) fieldNumbers() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, idx := range m.index() {
+		for _, n := range idx.numbers {
+			if !seen[n] {
+				seen[n] = true
+				numbers = append(numbers, n)
+			}
+		}
+	}
+
+	return numbers
+}
+
+// decode decodes the value of the field, must be called with the lock held.
+func (m *LazyMessage) decode(fd protoreflect.FieldDescriptor) (protoreflect.Value, error) {
+	occs := m.occurrencesLocked(fd)
+	switch {
+	case fd.IsMap():
+		return decodeLazyMap(fd, occs)
+	case fd.IsList():
+		return decodeLazyList(fd, occs)
+	case fd.Message() != nil:
+		if len(occs) == 0 {
+			return emptyValue(fd), nil
+		}
+
+		chunks := make([][]byte, 0, len(occs))
+		for _, o := range occs {
+			payload, err := fieldScanner(o.data, int(fd.Number()), o.field).MessageData()
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			chunks = append(chunks, payload)
+		}
+
+		return protoreflect.ValueOfMessage(readOnlyLazyMessage(fd.Message(), chunks...)), nil
+	case len(occs) == 0:
+		return fd.Default(), nil
+	}
+
+	o := occs[len(occs)-1]
+	return decodeReflect(fieldScanner(o.data, int(fd.Number()), o.field), fd)
+}
+
+// decodeReflect decodes a non-message value of the field.
+func decodeReflect(msg *Message, fd protoreflect.FieldDescriptor) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v, err := msg.String()
+		return protoreflect.ValueOfString(v), err
+	case protoreflect.BytesKind:
+		v, err := msg.Bytes()
+		return protoreflect.ValueOfBytes(v), err
+	}
+
+	return reflectScalar(&msg.base, fd.Kind())
+}
+
+// reflectScalar reads a varint or fixed size value of the kind.
+func reflectScalar(b *base, kind protoreflect.Kind) (protoreflect.Value, error) {
+	switch kind {
+	case protoreflect.BoolKind:
+		v, err := b.Bool()
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.EnumKind:
+		v, err := b.Int32()
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	case protoreflect.Int32Kind:
+		v, err := b.Int32()
+		return protoreflect.ValueOfInt32(v), err
+	case protoreflect.Sint32Kind:
+		v, err := b.Sint32()
+		return protoreflect.ValueOfInt32(v), err
+	case protoreflect.Sfixed32Kind:
+		v, err := b.Sfixed32()
+		return protoreflect.ValueOfInt32(v), err
+	case protoreflect.Int64Kind:
+		v, err := b.Int64()
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Sint64Kind:
+		v, err := b.Sint64()
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Sfixed64Kind:
+		v, err := b.Sfixed64()
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind:
+		v, err := b.Uint32()
+		return protoreflect.ValueOfUint32(v), err
+	case protoreflect.Fixed32Kind:
+		v, err := b.Fixed32()
+		return protoreflect.ValueOfUint32(v), err
+	case protoreflect.Uint64Kind:
+		v, err := b.Uint64()
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.Fixed64Kind:
+		v, err := b.Fixed64()
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := b.Float()
+		return protoreflect.ValueOfFloat32(v), err
+	case protoreflect.DoubleKind:
+		v, err := b.Double()
+		return protoreflect.ValueOfFloat64(v), err
+	}
+
+	return protoreflect.Value{}, ErrInvalidWireType
+}
+
+func decodeLazyList(fd protoreflect.FieldDescriptor, occs []lazyOccurrence) (protoreflect.Value, error) {
+	list := &lazyList{fd: fd}
+	for _, o := range occs {
+		msg := fieldScanner(o.data, int(fd.Number()), o.field)
+		if fd.Message() != nil {
+			payload, err := msg.MessageData()
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+
+			list.values = append(list.values, protoreflect.ValueOfMessage(readOnlyLazyMessage(fd.Message(), payload)))
+			continue
+		}
+
+		if o.wireType == WireTypeLengthDelimited && kindWireType(fd.Kind()) != WireTypeLengthDelimited {
+			iter, err := msg.Iterator(nil)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+
+			for iter.HasNext() {
+				v, err := reflectScalar(&iter.base, fd.Kind())
+				if err != nil {
+					return protoreflect.Value{}, err
+				}
+				list.values = append(list.values, v)
+			}
+			continue
+		}
+
+		v, err := decodeReflect(msg, fd)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		list.values = append(list.values, v)
+	}
+
+	return protoreflect.ValueOfList(list), nil
+}
+
+func decodeLazyMap(fd protoreflect.FieldDescriptor, occs []lazyOccurrence) (protoreflect.Value, error) {
+	m := &lazyMap{fd: fd, values: make(map[any]protoreflect.Value)}
+	keyFD, valueFD := fd.MapKey(), fd.MapValue()
+	for _, o := range occs {
+		payload, err := fieldScanner(o.data, int(fd.Number()), o.field).MessageData()
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+
+		entry := NewLazyMessage(payload, fd.Message())
+		key := entry.Get(keyFD).MapKey()
+		value := entry.Get(valueFD)
+		if err := entry.Error(); err != nil {
+			return protoreflect.Value{}, err
+		}
+
+		if valueFD.Message() != nil && !value.Message().IsValid() {
+			value = protoreflect.ValueOfMessage(readOnlyLazyMessage(valueFD.Message(), nil))
+		}
+
+		if _, ok := m.values[key.Interface()]; !ok {
+			m.keys = append(m.keys, key)
+		}
+		m.values[key.Interface()] = value
+	}
+
+	return protoreflect.ValueOfMap(m), nil
+}
+
+// emptyValue returns the read-only empty value of the field.
+func emptyValue(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch {
+	case fd.IsMap():
+		return protoreflect.ValueOfMap(&lazyMap{fd: fd})
+	case fd.IsList():
+		return protoreflect.ValueOfList(&lazyList{fd: fd})
+	case fd.Message() != nil:
+		return protoreflect.ValueOfMessage(newLazyMessage(fd.Message(), nil, false))
+	}
+
+	return fd.Default()
+}
+
+// isZeroReflect returns true if the scalar value is the zero value,
+// negative zero floats are not.
+func isZeroReflect(v protoreflect.Value) bool {
+	switch x := v.Interface().(type) {
+	case bool:
+		return !x
+	case int32:
+		return x == 0
+	case int64:
+		return x == 0
+	case uint32:
+		return x == 0
+	case uint64:
+		return x == 0
+	case protoreflect.EnumNumber:
+		return x == 0
+	case float32:
+		return math.Float32bits(x) == 0
+	case float64:
+		return math.Float64bits(x) == 0
+	case string:
+		return x == ""
+	case []byte:
+		return len(x) == 0
+	}
+
+	return false
+}
+
+// lazyList is a read-only list of decoded values.
+type lazyList struct {
+	fd     protoreflect.FieldDescriptor
+	values []protoreflect.Value
+}
+
+func (l *lazyList) Len() int                          { return len(l.values) }
+func (l *lazyList) Get(i int) protoreflect.Value      { return l.values[i] }
+func (l *lazyList) Set(int, protoreflect.Value)       { panic("protoscan: change to a read-only list") }
+func (l *lazyList) Append(protoreflect.Value)         { panic("protoscan: change to a read-only list") }
+func (l *lazyList) AppendMutable() protoreflect.Value { panic("protoscan: change to a read-only list") }
+func (l *lazyList) Truncate(int)                      { panic("protoscan: change to a read-only list") }
+func (l *lazyList) IsValid() bool                     { return l.values != nil }
+
+func (l *lazyList) NewElement() protoreflect.Value {
+	return dynamicpb.NewMessage(l.fd.ContainingMessage()).NewField(l.fd).List().NewElement()
+}
+
+// lazyMap is a read-only map of decoded values.
+type lazyMap struct {
+	fd     protoreflect.FieldDescriptor
+	keys   []protoreflect.MapKey
+	values map[any]protoreflect.Value
+}
+
+func (m *lazyMap) Len() int                  { return len(m.values) }
+func (m *lazyMap) Clear(protoreflect.MapKey) { panic("protoscan: change to a read-only map") }
+func (m *lazyMap) Set(protoreflect.MapKey, protoreflect.Value) {
+	panic("protoscan: change to a read-only map")
+}
+func (m *lazyMap) Mutable(protoreflect.MapKey) protoreflect.Value {
+	panic("protoscan: change to a read-only map")
+}
+func (m *lazyMap) IsValid() bool { return m.values != nil }
+
+func (m *lazyMap) Range(f func(protoreflect.MapKey, protoreflect.Value) bool) {
+	for _, k := range m.keys {
+		if !f(k, m.values[k.Interface()]) {
+			return
+		}
+	}
+}
+
+func (m *lazyMap) Has(k protoreflect.MapKey) bool {
+	_, ok := m.values[k.Interface()]
+	return ok
+}
+
+func (m *lazyMap) Get(k protoreflect.MapKey) protoreflect.Value {
+	return m.values[k.Interface()]
+}
+
+func (m *lazyMap) NewValue() protoreflect.Value {
+	return dynamicpb.NewMessage(m.fd.ContainingMessage()).NewField(m.fd).Map().NewValue()
+}
+
+// lazyType is the message type of lazy messages.
+type lazyType struct {
+	md protoreflect.MessageDescriptor
+}
+
+func (t lazyType) New() protoreflect.Message {
+	return NewLazyMessage(nil, t.md)
+}
+
+func (t lazyType) Zero() protoreflect.Message {
+	return newLazyMessage(t.md, nil, false)
+}
+
+func (t lazyType) Descriptor() protoreflect.MessageDescriptor {
+	return t.md
+}
@@ -0,0 +1,195 @@
+package pbr
+
+import (
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestLazyMessage(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 2, Open: true, Items: []*testmsg.Item{{Id: 3}}}, {Id: 4}},
+		FavoriteIds: []int64{5, 6},
+	}
+	md := c.ProtoReflect().Descriptor()
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+	data = append(data, 0xf8, 0x01, 0x07) // unknown field 31
+
+	m := NewLazyMessage(data, md)
+	if v := m.Get(md.Fields().ByName("username")).String(); v != "name" {
+		t.Errorf("incorrect username: %v", v)
+	}
+
+	orders := m.Get(md.Fields().ByName("orders")).List()
+	if orders.Len() != 2 {
+		t.Fatalf("incorrect orders: %v", orders.Len())
+	}
+
+	order := orders.Get(0).Message()
+	items := order.Get(order.Descriptor().Fields().ByName("items")).List()
+	if items.Len() != 1 {
+		t.Errorf("incorrect items: %v", items.Len())
+	}
+
+	if !m.Has(md.Fields().ByName("id")) || !m.Has(md.Fields().ByName("orders")) {
+		t.Errorf("fields should be set")
+	}
+
+	if string(m.GetUnknown()) != "\xf8\x01\x07" {
+		t.Errorf("incorrect unknown fields: %x", m.GetUnknown())
+	}
+
+	expected := proto.Clone(c)
+	expected.ProtoReflect().SetUnknown(protoreflect.RawFields{0xf8, 0x01, 0x07})
+	if !proto.Equal(m, expected) {
+		t.Errorf("messages should be equal:\n%v", prototext.Format(m))
+	}
+
+	encoded, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	result := &testmsg.Customer{}
+	if err := proto.Unmarshal(encoded, result); err != nil || !proto.Equal(result, expected) {
+		t.Errorf("incorrect message: %v %e", result, err)
+	}
+
+	if m.Mutated() || m.Error() != nil {
+		t.Errorf("message should not be changed: %e", m.Error())
+	}
+
+	m.Set(md.Fields().ByName("username"), protoreflect.ValueOfString("other"))
+	if !m.Mutated() {
+		t.Errorf("message should be changed")
+	}
+
+	expected.(*testmsg.Customer).Username = "other"
+	if !proto.Equal(m, expected) {
+		t.Errorf("messages should be equal:\n%v", prototext.Format(m))
+	}
+}
+
+func TestLazyMessage_legacy(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+	data := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1
+		last { id: 2 }
+		by_name { key: "a" value { id: 3 open: true } }
+		by_name { key: "b" }`))
+	data = appendBytes(data, 5, []byte{0x10, 0x01}) // merged into last
+
+	m := NewLazyMessage(data, md)
+	expected := newLegacy(t, "Customer", `
+		id: 1
+		last { id: 2 open: true }
+		by_name { key: "a" value { id: 3 open: true } }
+		by_name { key: "b" value { } }`)
+	if !proto.Equal(m, expected) {
+		t.Errorf("messages should be equal:\n%v", prototext.Format(m))
+	}
+
+	byName := m.Get(md.Fields().ByName("by_name")).Map()
+	if !byName.Has(protoreflect.ValueOfString("a").MapKey()) || byName.Len() != 2 {
+		t.Errorf("incorrect map: %v", byName.Len())
+	}
+
+	order := legacyFile.Messages().ByName("Order")
+	last := m.Get(md.Fields().ByName("last")).Message()
+	if v := last.Get(order.Fields().ByName("priority")).Int(); v != 3 {
+		t.Errorf("default should be returned: %v", v)
+	}
+
+	if last.Has(order.Fields().ByName("priority")) {
+		t.Errorf("priority should not be set")
+	}
+
+	empty := NewLazyMessage(nil, md)
+	if empty.Get(md.Fields().ByName("last")).Message().IsValid() {
+		t.Errorf("unset message should not be valid")
+	}
+
+	if empty.Get(md.Fields().ByName("orders")).List().Len() != 0 {
+		t.Errorf("unset list should be empty")
+	}
+}
+
+func TestLazyMessage_oneof(t *testing.T) {
+	md := (&structpb.Value{}).ProtoReflect().Descriptor()
+
+	var data []byte
+	data = appendBytes(data, 3, []byte("str"))
+	data = appendTag(data, 4, WireTypeVarint)
+	data = appendVarint(data, 1)
+
+	m := NewLazyMessage(data, md)
+	od := md.Oneofs().ByName("kind")
+	if fd := m.WhichOneof(od); fd == nil || fd.Name() != "bool_value" {
+		t.Errorf("incorrect oneof: %v", fd)
+	}
+
+	if m.Has(md.Fields().ByName("string_value")) {
+		t.Errorf("only the last oneof field should be set")
+	}
+
+	if !proto.Equal(m, structpb.NewBoolValue(true)) {
+		t.Errorf("messages should be equal:\n%v", prototext.Format(m))
+	}
+}
+
+func TestLazyMessage_invalid(t *testing.T) {
+	md := (&testmsg.Customer{}).ProtoReflect().Descriptor()
+
+	m := NewLazyMessage([]byte{0x12, 0x05, 'a'}, md)
+	if m.Has(md.Fields().ByName("username")) {
+		t.Errorf("invalid field should not be set")
+	}
+
+	if m.Error() == nil {
+		t.Errorf("error should be returned")
+	}
+}
+
+func TestLazyMessage_mutable(t *testing.T) {
+	p := &testmsg.Parent{Child: &testmsg.Child{Number: 1}, After: true}
+	md := p.ProtoReflect().Descriptor()
+	childFD, afterFD := md.Fields().ByName("child"), md.Fields().ByName("after")
+	numberFD := childFD.Message().Fields().ByName("number")
+
+	data, err := proto.Marshal(p)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("change to a read-only message should panic")
+			}
+		}()
+
+		m := NewLazyMessage(data, md)
+		m.Get(childFD).Message().Set(numberFD, protoreflect.ValueOfInt64(42))
+	})
+
+	t.Run("mutable", func(t *testing.T) {
+		m := NewLazyMessage(data, md)
+		m.Mutable(childFD).Message().Set(numberFD, protoreflect.ValueOfInt64(42))
+		m.Set(afterFD, protoreflect.ValueOfBool(false))
+
+		expected := &testmsg.Parent{Child: &testmsg.Child{Number: 42}}
+		if !proto.Equal(m, expected) {
+			t.Errorf("messages should be equal:\n%v", prototext.Format(m))
+		}
+	})
+}
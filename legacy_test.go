@@ -0,0 +1,175 @@
+package pbr
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// legacyFile is the proto2 version of the Customer message from the README.
+//
+//	message Customer {
+//	  required int64 id = 1;
+//	  optional string username = 2 [debug_redact = true];
+//	  repeated Order orders = 3;
+//	  repeated int64 favorite_ids = 4 [packed = true];
+//	  optional Order last = 5 [debug_redact = true];
+//	  map<string, Order> by_name = 6;
+//	  map<sint32, string> tags = 7;
+//	  extensions 100 to 199;
+//	}
+//
+//	message Order {
+//	  required int64 id = 1;
+//	  required bool open = 2;
+//	  repeated Item items = 3;
+//	  optional int32 priority = 4 [default = 3];
+//
+//	  extend Customer {
+//	    optional int64 order_count = 103;
+//	  }
+//	}
+//
+//	message Item {
+//	  optional int64 id = 1;
+//	}
+//
+//	extend Customer {
+//	  optional string nickname = 100;
+//	  repeated sint32 scores = 101 [packed = true];
+//	  optional Order pinned = 102;
+//	}
+var legacyFile = func() protoreflect.FileDescriptor {
+	field := func(name string, number int32, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  label.Enum(),
+			Type:   typ.Enum(),
+		}
+
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+
+		return fd
+	}
+
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		required = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+
+	favorites := field("favorite_ids", 4, repeated, descriptorpb.FieldDescriptorProto_TYPE_INT64, "")
+	favorites.Options = &descriptorpb.FieldOptions{Packed: proto.Bool(true)}
+
+	username := field("username", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	username.Options = &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}
+
+	last := field("last", 5, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Order")
+	last.Options = &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}
+
+	priority := field("priority", 4, optional, descriptorpb.FieldDescriptorProto_TYPE_INT32, "")
+	priority.DefaultValue = proto.String("3")
+
+	extension := func(fd *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		fd.Extendee = proto.String(".legacy.Customer")
+		return fd
+	}
+
+	scores := extension(field("scores", 101, repeated, descriptorpb.FieldDescriptorProto_TYPE_SINT32, ""))
+	scores.Options = &descriptorpb.FieldOptions{Packed: proto.Bool(true)}
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("legacy.proto"),
+		Package: proto.String("legacy"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Customer"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, required, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					username,
+					field("orders", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Order"),
+					favorites,
+					last,
+					field("by_name", 6, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Customer.ByNameEntry"),
+					field("tags", 7, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Customer.TagsEntry"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("ByNameEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+						field("value", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Order"),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}, {
+					Name: proto.String("TagsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_SINT32, ""),
+						field("value", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+				ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{
+					{Start: proto.Int32(100), End: proto.Int32(200)},
+				},
+			},
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, required, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					field("open", 2, required, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+					field("items", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Item"),
+					priority,
+				},
+				Extension: []*descriptorpb.FieldDescriptorProto{
+					extension(field("order_count", 103, optional, descriptorpb.FieldDescriptorProto_TYPE_INT64, "")),
+				},
+			},
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				},
+			},
+		},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			extension(field("nickname", 100, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")),
+			scores,
+			extension(field("pinned", 102, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".legacy.Order")),
+		},
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return fd
+}()
+
+// newLegacy creates a dynamic message of the legacy file from its text format.
+func newLegacy(t testing.TB, name, text string) *dynamicpb.Message {
+	t.Helper()
+	msg := dynamicpb.NewMessage(legacyFile.Messages().ByName(protoreflect.Name(name)))
+	if err := (prototext.UnmarshalOptions{AllowPartial: true}).Unmarshal([]byte(text), msg); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	return msg
+}
+
+func marshalPartial(t testing.TB, m proto.Message) []byte {
+	t.Helper()
+	data, err := proto.MarshalOptions{AllowPartial: true}.Marshal(m)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	return data
+}
@@ -0,0 +1,221 @@
+package pbr
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Marshal encodes the struct, or pointer to a struct, using the pbr struct
+// tags of its fields, see Unmarshal for the format.
+// Fields are written in field number order, zero values are omitted,
+// except for set pointers to structs, and repeated scalars are packed
+// unless tagged 'unpacked'. Map entries are sorted by key
+// so the encoding is deterministic.
+func Marshal(v any) ([]byte, error) {
+	return AppendMarshal(nil, v)
+}
+
+// AppendMarshal appends the encoding of the struct to the buffer, see Marshal.
+func AppendMarshal(buf []byte, v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: Marshal requires a struct, got %T", ErrInvalidTag, v)
+	}
+
+	p, err := planFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.encode(buf, rv), nil
+}
+
+func (p *structPlan) encode(buf []byte, rv reflect.Value) []byte {
+	for _, f := range p.fields {
+		fv := rv.Field(f.index)
+		switch {
+		case f.isMap:
+			buf = f.encodeMap(buf, fv)
+		case f.repeated:
+			buf = f.encodeRepeated(buf, fv)
+		case f.kind == protoreflect.MessageKind:
+			if f.pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+
+			start := len(buf)
+			buf = f.encodeValue(buf, f.number, fv)
+			if !f.pointer && len(buf) == start+sizeVarint(uint64(f.number)<<3)+1 {
+				buf = buf[:start] // empty struct value
+			}
+		case !isZeroKind(f.kind, fv):
+			buf = f.encodeValue(buf, f.number, fv)
+		}
+	}
+
+	return buf
+}
+
+func (f *fieldPlan) encodeRepeated(buf []byte, fv reflect.Value) []byte {
+	n := fv.Len()
+	if n == 0 {
+		return buf
+	}
+
+	if f.unpacked || kindWireType(f.kind) == WireTypeLengthDelimited {
+		for i := 0; i < n; i++ {
+			buf = f.encodeValue(buf, f.number, fv.Index(i))
+		}
+		return buf
+	}
+
+	buf = appendTag(buf, f.number, WireTypeLengthDelimited)
+	buf, start := beginLength(buf)
+	for i := 0; i < n; i++ {
+		buf = appendKind(buf, f.kind, fv.Index(i))
+	}
+
+	return endLength(buf, start)
+}
+
+// encodeMap appends the entries sorted by key, keys and values are always written.
+func (f *fieldPlan) encodeMap(buf []byte, fv reflect.Value) []byte {
+	if fv.Len() == 0 {
+		return buf
+	}
+
+	keys := fv.MapKeys()
+	slices.SortFunc(keys, compareKeys)
+
+	keyPlan := &fieldPlan{kind: f.keyKind}
+	var start int
+	for _, k := range keys {
+		buf = appendTag(buf, f.number, WireTypeLengthDelimited)
+		buf, start = beginLength(buf)
+		buf = keyPlan.encodeValue(buf, 1, k)
+
+		v := fv.MapIndex(k)
+		if f.pointer {
+			if v.IsNil() {
+				v = reflect.New(f.elem.Elem())
+			}
+			v = v.Elem()
+		}
+
+		buf = endLength(f.encodeValue(buf, 2, v), start)
+	}
+
+	return buf
+}
+
+// encodeValue appends the value with the tag, for pointers the value is the struct.
+func (f *fieldPlan) encodeValue(buf []byte, number int, v reflect.Value) []byte {
+	buf = appendTag(buf, number, kindWireType(f.kind))
+	switch f.kind {
+	case protoreflect.MessageKind:
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return append(buf, 0)
+			}
+			v = v.Elem()
+		}
+
+		buf, start := beginLength(buf)
+		return endLength(f.message.encode(buf, v), start)
+	case protoreflect.StringKind:
+		s := v.String()
+		buf = appendVarint(buf, uint64(len(s)))
+		return append(buf, s...)
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		buf = appendVarint(buf, uint64(len(b)))
+		return append(buf, b...)
+	}
+
+	return appendKind(buf, f.kind, v)
+}
+
+// appendKind appends a varint or fixed size value of the kind.
+func appendKind(buf []byte, kind protoreflect.Kind, v reflect.Value) []byte {
+	switch kind {
+	case protoreflect.BoolKind:
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case protoreflect.Int32Kind, protoreflect.EnumKind:
+		return appendVarint(buf, uint64(int32(v.Int())))
+	case protoreflect.Int64Kind:
+		return appendVarint(buf, uint64(v.Int()))
+	case protoreflect.Sint32Kind:
+		return appendVarint(buf, zigzag32(int32(v.Int())))
+	case protoreflect.Sint64Kind:
+		return appendVarint(buf, zigzag64(v.Int()))
+	case protoreflect.Uint32Kind:
+		return appendVarint(buf, uint64(uint32(v.Uint())))
+	case protoreflect.Uint64Kind:
+		return appendVarint(buf, v.Uint())
+	case protoreflect.Sfixed32Kind:
+		return appendFixed32(buf, uint32(v.Int()))
+	case protoreflect.Fixed32Kind:
+		return appendFixed32(buf, uint32(v.Uint()))
+	case protoreflect.Sfixed64Kind:
+		return appendFixed64(buf, uint64(v.Int()))
+	case protoreflect.Fixed64Kind:
+		return appendFixed64(buf, v.Uint())
+	case protoreflect.FloatKind:
+		return appendFixed32(buf, math.Float32bits(float32(v.Float())))
+	case protoreflect.DoubleKind:
+		return appendFixed64(buf, math.Float64bits(v.Float()))
+	}
+
+	return buf
+}
+
+// isZeroKind returns true if the value of a scalar field is omitted.
+// Negative zero floats are written like the protobuf implementations.
+func isZeroKind(kind protoreflect.Kind, v reflect.Value) bool {
+	switch kind {
+	case protoreflect.FloatKind:
+		return math.Float32bits(float32(v.Float())) == 0
+	case protoreflect.DoubleKind:
+		return math.Float64bits(v.Float()) == 0
+	case protoreflect.BytesKind:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+func compareKeys(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	}
+
+	return cmp.Compare(a.Uint(), b.Uint())
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
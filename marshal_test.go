@@ -0,0 +1,160 @@
+package pbr
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestMarshal_scalar(t *testing.T) {
+	v := taggedScalar{
+		Flt: 1.5, Dbl: -2.5, I32: -3, I64: -4, U32: 5, U64: 6,
+		S32: -7, S64: -8, F32: 9, F64: 10, SF32: -11, SF64: -12,
+		Bool: true, Str: "str", Byte: []byte("byte"), After: true,
+	}
+
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	expected, err := proto.Marshal(&testmsg.Scalar{
+		Flt: 1.5, Dbl: -2.5, I32: -3, I64: -4, U32: 5, U64: 6,
+		S32: -7, S64: -8, F32: 9, F64: 10, Sf32: -11, Sf64: -12,
+		Bool: true, Str: "str", Byte: []byte("byte"), After: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Errorf("incorrect data:\n%x\n%x", data, expected)
+	}
+
+	// zero values are omitted, negative zero is not
+	data, err = Marshal(&taggedScalar{Dbl: math.Copysign(0, -1), Byte: []byte{}})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	if !bytes.Equal(data, []byte{0x11, 0, 0, 0, 0, 0, 0, 0, 0x80}) {
+		t.Errorf("incorrect data: %x", data)
+	}
+}
+
+func TestMarshal_repeated(t *testing.T) {
+	v := taggedRepeated{
+		Flt: []float32{1, 2}, Dbl: []float64{3}, I32: []int32{-1, 2}, I64: []int64{3, 300},
+		U32: []uint32{4}, U64: []uint64{5}, S32: []int32{-6}, S64: []int64{-7},
+		F32: []uint32{8}, F64: []uint64{9}, SF32: []int32{-10}, SF64: []int64{-11},
+		Bool: []bool{true, false}, Str: []string{"a", "b"}, Byte: [][]byte{[]byte("c")},
+	}
+
+	data, err := AppendMarshal([]byte{0x01}, &v)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	if data[0] != 0x01 {
+		t.Errorf("buffer should be appended to")
+	}
+
+	expected, err := proto.Marshal(&testmsg.Packed{
+		Flt: v.Flt, Dbl: v.Dbl, I32: v.I32, I64: v.I64,
+		U32: v.U32, U64: v.U64, S32: v.S32, S64: v.S64,
+		F32: v.F32, F64: v.F64, Sf32: v.SF32, Sf64: v.SF64,
+		Bool: v.Bool, Str: v.Str, Byte: v.Byte,
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	if !bytes.Equal(data[1:], expected) {
+		t.Errorf("incorrect data:\n%x\n%x", data[1:], expected)
+	}
+
+	unpacked := struct {
+		V []int64 `pbr:"1,unpacked"`
+	}{V: []int64{1, 2}}
+
+	data, err = Marshal(unpacked)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	if !bytes.Equal(data, []byte{0x08, 0x01, 0x08, 0x02}) {
+		t.Errorf("incorrect data: %x", data)
+	}
+}
+
+func TestMarshal_nested(t *testing.T) {
+	v := taggedCustomer{
+		ID:       1,
+		Username: strings.Repeat("long", 100),
+		Orders: []*taggedOrder{
+			{ID: 2, Open: true, Items: []taggedItem{{ID: 3}, {}}},
+			nil,
+		},
+		FavoriteIDs: []int64{6, 7},
+		Last:        &taggedOrder{},
+		ByName: map[string]taggedOrder{
+			"b": {},
+			"a": {ID: 9, Priority: 1, Items: make([]taggedItem, 50)},
+		},
+	}
+
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	again, err := Marshal(v)
+	if err != nil || !bytes.Equal(data, again) {
+		t.Errorf("encoding should be deterministic: %e", err)
+	}
+
+	md := legacyFile.Messages().ByName("Customer")
+	result := dynamicpb.NewMessage(md)
+	if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(data, result); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := newLegacy(t, "Customer", `
+		id: 1 username: "`+v.Username+`"
+		orders { id: 2 open: true items { id: 3 } items { } }
+		orders { }
+		favorite_ids: [6, 7]
+		last { }
+		by_name { key: "a" value { id: 9 priority: 1 `+strings.Repeat("items { } ", 50)+` } }
+		by_name { key: "b" value { } }`)
+	if !proto.Equal(result, expected) {
+		t.Errorf("incorrect message: %v", result)
+	}
+
+	var decoded taggedCustomer
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	v.Orders[1] = &taggedOrder{}
+	compare(t, decoded, v)
+}
+
+func TestMarshal_errors(t *testing.T) {
+	if _, err := Marshal(1); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("non struct should fail: %v", err)
+	}
+
+	invalid := struct {
+		V string `pbr:"1,sint64"`
+	}{}
+	if _, err := Marshal(invalid); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("invalid tag should fail: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package pbr
+
+import (
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// MaskOptions configures MergeMasked.
+type MaskOptions struct {
+	// AppendRepeated appends the values of masked repeated fields
+	// to the destination instead of replacing them.
+	AppendRepeated bool
+}
+
+// ApplyMask returns a copy of the message with only the fields in the mask.
+// Field occurrences are copied unchanged, embedded messages on a path
+// are re-encoded with only the masked fields.
+func ApplyMask(data []byte, md protoreflect.MessageDescriptor, mask *fieldmaskpb.FieldMask) ([]byte, error) {
+	tree, err := maskTree(md, mask)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectPaths(nil, data, tree)
+}
+
+// MergeMasked returns dst updated with the fields in the mask from src.
+// A masked field is replaced with its value in src, or cleared if it's not set
+// in src, other fields of dst are kept. Embedded messages on a path are merged.
+func MergeMasked(dst, src []byte, md protoreflect.MessageDescriptor, mask *fieldmaskpb.FieldMask, opts MaskOptions) ([]byte, error) {
+	tree, err := maskTree(md, mask)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeMasked(nil, [][]byte{dst}, [][]byte{src}, tree, md, opts)
+}
+
+// maskTree parses the paths of the mask.
+func maskTree(md protoreflect.MessageDescriptor, mask *fieldmaskpb.FieldMask) (pathTree, error) {
+	paths := make([]Path, 0, len(mask.GetPaths()))
+	for _, p := range mask.GetPaths() {
+		path, err := ParsePath(p, md)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return newPathTree(paths), nil
+}
+
+// selectPaths appends the field occurrences in the tree.
+func selectPaths(buf, data []byte, tree pathTree) ([]byte, error) {
+	var err error
+	scanErr := scanFields(data, func(n int, f field) {
+		node, ok := tree[n]
+		if err != nil || !ok {
+			return
+		}
+
+		if len(node) == 0 {
+			buf = append(buf, data[f.start:f.end]...)
+			return
+		}
+
+		if f.wireType != WireTypeLengthDelimited {
+			err = ErrInvalidWireType
+			return
+		}
+
+		var payload, sub []byte
+		if payload, err = fieldScanner(data, n, f).MessageData(); err != nil {
+			return
+		}
+
+		sub, err = selectPaths(nil, payload, node)
+		buf = appendBytes(buf, n, sub)
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	return buf, err
+}
+
+// mergeMasked appends the fields of dst that aren't masked, then the masked
+// fields from src, or both for repeated fields with AppendRepeated.
+// Chunks are parts of the same message.
+func mergeMasked(buf []byte, dst, src [][]byte, tree pathTree, md protoreflect.MessageDescriptor, opts MaskOptions) ([]byte, error) {
+	dv, err := Merged(nil, dst...)
+	if err != nil {
+		return nil, err
+	}
+
+	sv, err := Merged(nil, src...)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := func(n int) bool {
+		node, ok := tree[n]
+		if !ok {
+			return true
+		}
+
+		fd := md.Fields().ByNumber(protoreflect.FieldNumber(n))
+		return len(node) == 0 && opts.AppendRepeated && fd != nil && fd.IsList()
+	}
+
+	// all the occurrences of the fields in the order of dst
+	for _, chunk := range dst {
+		err := scanFields(chunk, func(n int, f field) {
+			if keep(n) {
+				buf = append(buf, chunk[f.start:f.end]...)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	numbers := make([]int, 0, len(tree))
+	for n := range tree {
+		numbers = append(numbers, n)
+	}
+	slices.Sort(numbers)
+
+	for _, n := range numbers {
+		node := tree[n]
+		if len(node) == 0 {
+			for _, o := range sv.fields[n] {
+				buf = append(buf, o.data[o.start:o.end]...)
+			}
+			continue
+		}
+
+		fd := md.Fields().ByNumber(protoreflect.FieldNumber(n))
+		if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("%w: field %d of %s is not a singular message", ErrInvalidPath, n, md.FullName())
+		}
+
+		if !dv.Has(n) && !sv.Has(n) {
+			continue
+		}
+
+		dp, err := dv.payloads(n)
+		if err != nil {
+			return nil, err
+		}
+
+		sp, err := sv.payloads(n)
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := mergeMasked(nil, dp, sp, node, fd.Message(), opts)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendBytes(buf, n, sub)
+	}
+
+	return buf, nil
+}
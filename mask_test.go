@@ -0,0 +1,128 @@
+package pbr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestApplyMask(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1, Open: true}, {Id: 2, Items: []*testmsg.Item{{Id: 3}}}},
+		FavoriteIds: []int64{1, 2},
+	}
+	md := c.ProtoReflect().Descriptor()
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	masked, err := ApplyMask(data, md, &fieldmaskpb.FieldMask{Paths: []string{"username", "orders.id", "favorite_ids"}})
+	if err != nil {
+		t.Fatalf("unable to apply mask: %e", err)
+	}
+
+	result := &testmsg.Customer{}
+	if err := proto.Unmarshal(masked, result); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := &testmsg.Customer{
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1}, {Id: 2}},
+		FavoriteIds: []int64{1, 2},
+	}
+	if !proto.Equal(result, expected) {
+		t.Errorf("incorrect message: %v", result)
+	}
+
+	if _, err := ApplyMask(data, md, &fieldmaskpb.FieldMask{Paths: []string{"missing"}}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("unknown field should fail: %v", err)
+	}
+}
+
+func TestMergeMasked(t *testing.T) {
+	dst, err := proto.Marshal(&testmsg.Parent{
+		Child: &testmsg.Child{
+			Number:     1,
+			Numbers:    []int64{1},
+			Grandchild: []*testmsg.Grandchild{{Number: 1}},
+		},
+		After: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	src, err := proto.Marshal(&testmsg.Parent{
+		Child: &testmsg.Child{
+			Number:     2,
+			Numbers:    []int64{2},
+			Grandchild: []*testmsg.Grandchild{{Number: 2}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	md := (&testmsg.Parent{}).ProtoReflect().Descriptor()
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"child.number", "child.numbers", "after"}}
+
+	cases := []struct {
+		name     string
+		opts     MaskOptions
+		expected *testmsg.Parent
+	}{
+		{
+			name: "replace",
+			expected: &testmsg.Parent{
+				Child: &testmsg.Child{
+					Number:     2,
+					Numbers:    []int64{2},
+					Grandchild: []*testmsg.Grandchild{{Number: 1}},
+				},
+			},
+		},
+		{
+			name: "append",
+			opts: MaskOptions{AppendRepeated: true},
+			expected: &testmsg.Parent{
+				Child: &testmsg.Child{
+					Number:     2,
+					Numbers:    []int64{1, 2},
+					Grandchild: []*testmsg.Grandchild{{Number: 1}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := MergeMasked(dst, src, md, mask, tc.opts)
+			if err != nil {
+				t.Fatalf("unable to merge: %e", err)
+			}
+
+			result := &testmsg.Parent{}
+			if err := proto.Unmarshal(merged, result); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+
+			if !proto.Equal(result, tc.expected) {
+				t.Errorf("incorrect message: %v", result)
+			}
+		})
+	}
+
+	cmd := (&testmsg.Customer{}).ProtoReflect().Descriptor()
+	_, err = MergeMasked(nil, nil, cmd, &fieldmaskpb.FieldMask{Paths: []string{"orders.id"}}, MaskOptions{})
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("path through a repeated field should fail: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package pbr
+
+// MergedView presents the fields of one or more encoded messages
+// with the protobuf merge semantics applied: for non-repeated fields
+// the last occurrence wins, repeated fields are appended and
+// embedded messages are merged recursively.
+// With a descriptor, only the member of a oneof that occurs last is kept.
+// This is what proto.Unmarshal does with duplicate fields
+// or concatenated messages.
+type MergedView struct {
+	schema  *Schema
+	fields  map[int][]occurrence
+	numbers []int
+}
+
+// occurrence is a field occurrence in one of the merged messages.
+type occurrence struct {
+	data []byte
+	field
+	seq int // position in all the occurrences
+}
+
+// Merged creates a merged view of the concatenation of the encoded messages.
+// The schema is used to know which fields are repeated or
+// embedded messages, unknown fields are treated as repeated.
+func Merged(s *Schema, data ...[]byte) (*MergedView, error) {
+	v := &MergedView{
+		schema: s,
+		fields: make(map[int][]occurrence),
+	}
+
+	seq := 0
+	for _, d := range data {
+		err := scanFields(d, func(fieldNumber int, f field) {
+			if _, ok := v.fields[fieldNumber]; !ok {
+				v.numbers = append(v.numbers, fieldNumber)
+			}
+			v.fields[fieldNumber] = append(v.fields[fieldNumber], occurrence{data: d, field: f, seq: seq})
+			seq++
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v.resolveOneofs()
+	return v, nil
+}
+
+// resolveOneofs keeps only the oneof members that occur last, and only their
+// occurrences after the last one of another member, as setting
+// a member clears the others when decoding.
+func (v *MergedView) resolveOneofs() {
+	if v.schema == nil || v.schema.Descriptor == nil {
+		return
+	}
+
+	oneofs := v.schema.Descriptor.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		od := oneofs.Get(i)
+		if od.IsSynthetic() {
+			continue
+		}
+
+		winner, last, cleared := 0, -1, -1
+		fields := od.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			n := int(fields.Get(j).Number())
+			occs := v.fields[n]
+			if len(occs) == 0 {
+				continue
+			}
+
+			if seq := occs[len(occs)-1].seq; seq > last {
+				winner, cleared, last = n, max(cleared, last), seq
+			} else {
+				cleared = max(cleared, seq)
+			}
+		}
+
+		if last < 0 {
+			continue
+		}
+
+		for j := 0; j < fields.Len(); j++ {
+			n := int(fields.Get(j).Number())
+			if n != winner {
+				v.remove(n)
+			}
+		}
+
+		occs := v.fields[winner]
+		for len(occs) > 0 && occs[0].seq < cleared {
+			occs = occs[1:]
+		}
+		v.fields[winner] = occs
+	}
+}
+
+// remove removes all the occurrences of the field.
+func (v *MergedView) remove(fieldNumber int) {
+	if _, ok := v.fields[fieldNumber]; !ok {
+		return
+	}
+
+	delete(v.fields, fieldNumber)
+	for i, n := range v.numbers {
+		if n == fieldNumber {
+			v.numbers = append(v.numbers[:i:i], v.numbers[i+1:]...)
+			break
+		}
+	}
+}
+
+// FieldNumbers returns the field numbers found in the
+// messages in the order of their first occurrence.
+func (v *MergedView) FieldNumbers() []int {
+	return v.numbers
+}
+
+// Has returns true if the field occurs in the messages.
+func (v *MergedView) Has(fieldNumber int) bool {
+	return len(v.fields[fieldNumber]) > 0
+}
+
+// Lookup returns a scanner positioned on the effective value of
+// a non-repeated field, the last occurrence.
+// For embedded messages use Message to get all the occurrences merged.
+func (v *MergedView) Lookup(fieldNumber int) (*Message, bool) {
+	occs := v.fields[fieldNumber]
+	if len(occs) == 0 {
+		return nil, false
+	}
+
+	o := occs[len(occs)-1]
+	return fieldScanner(o.data, fieldNumber, o.field), true
+}
+
+// LookupAll returns a scanner for every occurrence of a repeated
+// field in order, the values of all of them should be appended.
+func (v *MergedView) LookupAll(fieldNumber int) []*Message {
+	occs := v.fields[fieldNumber]
+	if len(occs) == 0 {
+		return nil
+	}
+
+	result := make([]*Message, 0, len(occs))
+	for _, o := range occs {
+		result = append(result, fieldScanner(o.data, fieldNumber, o.field))
+	}
+
+	return result
+}
+
+// Message returns a merged view of all the occurrences of
+// a non-repeated embedded message field.
+// Returns nil if the field is not in the messages.
+func (v *MergedView) Message(fieldNumber int) (*MergedView, error) {
+	occs := v.fields[fieldNumber]
+	if len(occs) == 0 {
+		return nil, nil
+	}
+
+	sub, _ := v.schema.message(fieldNumber)
+	data, err := v.payloads(fieldNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return Merged(sub, data...)
+}
+
+// Merge merges the src encoded message into the dst encoded message
+// and returns a single encoding with the duplicate fields resolved.
+// Fields are written in the order of their first occurrence.
+// Unknown fields, not in the schema, are kept in order so that
+// decoding the result is the same as decoding the concatenation.
+func Merge(dst, src []byte, s *Schema) ([]byte, error) {
+	v, err := Merged(s, dst, src)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.appendTo(nil)
+}
+
+// appendTo appends the merged encoding of the view.
+func (v *MergedView) appendTo(buf []byte) ([]byte, error) {
+	for _, n := range v.numbers {
+		occs := v.fields[n]
+		if !v.schema.known(n) || v.schema.repeated(n) {
+			for _, o := range occs {
+				buf = append(buf, o.data[o.start:o.end]...)
+			}
+			continue
+		}
+
+		if _, ok := v.schema.message(n); !ok {
+			o := occs[len(occs)-1]
+			buf = append(buf, o.data[o.start:o.end]...)
+			continue
+		}
+
+		sub, err := v.Message(n)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := sub.appendTo(nil)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendBytes(buf, n, data)
+	}
+
+	return buf, nil
+}
+
+// payloads returns the data of the length-delimited occurrences of the field.
+func (v *MergedView) payloads(fieldNumber int) ([][]byte, error) {
+	occs := v.fields[fieldNumber]
+	result := make([][]byte, 0, len(occs))
+	for _, o := range occs {
+		if o.wireType != WireTypeLengthDelimited {
+			return nil, ErrInvalidWireType
+		}
+
+		d, err := fieldScanner(o.data, fieldNumber, o.field).MessageData()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+
+	return result, nil
+}
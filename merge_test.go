@@ -0,0 +1,203 @@
+package pbr
+
+import (
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestMerge(t *testing.T) {
+	a := &testmsg.Parent{
+		Child: &testmsg.Child{
+			Number:     1,
+			Numbers:    []int64{1},
+			Grandchild: []*testmsg.Grandchild{{Number: 10}},
+		},
+	}
+	b := &testmsg.Parent{
+		Child: &testmsg.Child{
+			Numbers:    []int64{2, 3},
+			Grandchild: []*testmsg.Grandchild{{Number: 20}},
+			After:      true,
+		},
+		After: true,
+	}
+
+	dataA, _ := proto.Marshal(a)
+	dataB, _ := proto.Marshal(b)
+
+	expected := &testmsg.Parent{}
+	if err := proto.Unmarshal(append(append([]byte{}, dataA...), dataB...), expected); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	schemas := map[string]*Schema{
+		"descriptor": {Descriptor: a.ProtoReflect().Descriptor()},
+		"explicit": {
+			Messages: map[int]*Schema{
+				1: {
+					Messages: map[int]*Schema{200: nil},
+					Repeated: map[int]bool{200: true, 300: true},
+				},
+			},
+		},
+	}
+
+	for name, s := range schemas {
+		t.Run(name, func(t *testing.T) {
+			merged, err := Merge(dataA, dataB, s)
+			if err != nil {
+				t.Fatalf("unable to merge: %e", err)
+			}
+
+			result := &testmsg.Parent{}
+			if err := proto.Unmarshal(merged, result); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+			compare(t, result, expected)
+
+			idx, err := Index(merged)
+			if err != nil {
+				t.Fatalf("unable to index: %e", err)
+			}
+
+			if c := idx.Count(1); c != 1 {
+				t.Errorf("child should be merged into one occurrence: %v", c)
+			}
+		})
+	}
+
+	t.Run("unknown fields", func(t *testing.T) {
+		merged, err := Merge(dataA, dataB, nil)
+		if err != nil {
+			t.Fatalf("unable to merge: %e", err)
+		}
+
+		result := &testmsg.Parent{}
+		if err := proto.Unmarshal(merged, result); err != nil {
+			t.Fatalf("unable to unmarshal: %e", err)
+		}
+		compare(t, result, expected)
+	})
+}
+
+func TestMergedView(t *testing.T) {
+	data := []byte{
+		0x08, 0x01, // id = 1
+		0x1a, 0x02, 0x08, 0x05, // orders { id: 5 }
+		0x08, 0x02, // id = 2
+		0x1a, 0x02, 0x08, 0x06, // orders { id: 6 }
+	}
+	more := []byte{
+		0x12, 0x01, 'a', // username = "a"
+		0x22, 0x01, 0x07, // favorite_ids = [7]
+	}
+
+	v, err := Merged(&Schema{Descriptor: (&testmsg.Customer{}).ProtoReflect().Descriptor()}, data, more)
+	if err != nil {
+		t.Fatalf("unable to create view: %e", err)
+	}
+
+	if ns := v.FieldNumbers(); len(ns) != 4 {
+		t.Errorf("incorrect field numbers: %v", ns)
+	}
+
+	msg, _ := v.Lookup(1)
+	if id, _ := msg.Int64(); id != 2 {
+		t.Errorf("last id should win: %v", id)
+	}
+
+	if c := len(v.LookupAll(3)); c != 2 {
+		t.Errorf("incorrect number of orders: %v", c)
+	}
+
+	msg, ok := v.Lookup(2)
+	if s, _ := msg.String(); !ok || s != "a" {
+		t.Errorf("incorrect username: %v", s)
+	}
+
+	// orders is repeated but merging all the occurrences as one
+	// message is valid and the last id wins.
+	order, err := v.Message(3)
+	if err != nil {
+		t.Fatalf("unable to merge orders: %e", err)
+	}
+
+	msg, _ = order.Lookup(1)
+	if id, _ := msg.Int64(); id != 6 {
+		t.Errorf("incorrect order id: %v", id)
+	}
+
+	if sub, err := v.Message(10); sub != nil || err != nil {
+		t.Errorf("missing field should return nil: %v %e", sub, err)
+	}
+
+	if _, err := v.Message(1); err != ErrInvalidWireType {
+		t.Errorf("incorrect error: %v", err)
+	}
+
+	if _, err := Merged(nil, []byte{0x0a, 0x05}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestMerge_oneof(t *testing.T) {
+	value := func(v *structpb.Value) []byte {
+		data, err := proto.Marshal(v)
+		if err != nil {
+			t.Fatalf("unable to marshal: %e", err)
+		}
+		return data
+	}
+
+	st := func(k string) *structpb.Value {
+		return structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{k: structpb.NewNumberValue(1)}})
+	}
+
+	cases := []struct {
+		name     string
+		dst, src []byte
+	}{
+		{
+			name: "scalar",
+			dst:  append(value(structpb.NewStringValue("a")), value(structpb.NewBoolValue(true))...),
+			src:  value(structpb.NewStringValue("b")),
+		},
+		{
+			name: "message",
+			dst:  append(value(st("a")), value(structpb.NewBoolValue(true))...),
+			src:  value(st("b")),
+		},
+		{
+			name: "same member",
+			dst:  value(st("a")),
+			src:  value(st("b")),
+		},
+	}
+
+	s := &Schema{Descriptor: (&structpb.Value{}).ProtoReflect().Descriptor()}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := &structpb.Value{}
+			if err := proto.Unmarshal(append(append([]byte{}, tc.dst...), tc.src...), expected); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+
+			merged, err := Merge(tc.dst, tc.src, s)
+			if err != nil {
+				t.Fatalf("unable to merge: %e", err)
+			}
+
+			result := &structpb.Value{}
+			if err := proto.Unmarshal(merged, result); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+
+			if !proto.Equal(result, expected) {
+				t.Errorf("incorrect message: %v != %v", result, expected)
+			}
+		})
+	}
+}
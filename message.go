@@ -22,12 +22,17 @@ var (
 	// ErrInvalidLength is returned when the length is not valid,
 	// usually as a result of an invalid type scan.
 	ErrInvalidLength = errors.New("protoscan: invalid length")
+	// ErrInvalidWireType is returned when the wire type is not valid
+	// for the operation, e.g. skipping a packed repeated field
+	// using a length-delimited wire type.
+	ErrInvalidWireType = errors.New("protoscan: invalid wire type")
 )
 
 // Message is a container for a protobuf message type ready to be scanned.
 type Message struct {
 	base
 	err         error
+	seen        *FieldSet
 	fieldNumber int
 	wireType    int
 }
@@ -65,6 +70,9 @@ func (m *Message) Next() bool {
 		} else {
 			m.fieldNumber = int(val >> 3)
 			m.wireType = int(val & 0x7)
+			if m.seen != nil {
+				m.seen.Add(m.fieldNumber)
+			}
 			return true
 		}
 	}
@@ -118,7 +126,7 @@ func (m *Message) Skip() {
 	case WireTypeVarint:
 		_, m.err = m.Varint64()
 	case WireType64bit:
-		if len(m.Data) <= m.Index+8 {
+		if len(m.Data) < m.Index+8 {
 			m.err = io.ErrUnexpectedEOF
 			return
 		}
@@ -131,11 +139,15 @@ func (m *Message) Skip() {
 		}
 		m.Index += l
 	case WireType32bit:
-		if len(m.Data) <= m.Index+4 {
+		if len(m.Data) < m.Index+4 {
 			m.err = io.ErrUnexpectedEOF
 			return
 		}
 		m.Index += 4
+	case WireTypeStartGroup, WireTypeEndGroup:
+		// groups have no length, only the tag is skipped.
+	default:
+		m.err = ErrInvalidWireType
 	}
 }
 
@@ -56,6 +56,31 @@ func TestMessage_Skip(t *testing.T) {
 	if err := msg.Error(); err != io.ErrUnexpectedEOF {
 		t.Errorf("incorrect error: %e", err)
 	}
+
+	// invalid wire type
+	msg.Reset([]byte{0x10 | 7, 0x01})
+	msg.Next()
+	msg.Skip()
+	if msg.Next() {
+		t.Errorf("should be false on if error")
+	}
+
+	if err := msg.Error(); err != ErrInvalidWireType {
+		t.Errorf("incorrect error: %e", err)
+	}
+
+	// fixed values at the end of the data
+	msg.Reset([]byte{
+		0x10 | WireType32bit, 1, 2, 3, 4,
+		0x18 | WireType64bit, 1, 2, 3, 4, 5, 6, 7, 8,
+	})
+	for msg.Next() {
+		msg.Skip()
+	}
+
+	if err := msg.Error(); err != nil {
+		t.Errorf("unexpected error: %e", err)
+	}
 }
 
 func TestMessage_MessageData(t *testing.T) {
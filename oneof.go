@@ -0,0 +1,107 @@
+package pbr
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// Oneof is the set of field numbers that are the members of a oneof.
+// When more than one member is in the encoded message
+// the last occurrence is the one that is set.
+type Oneof struct {
+	fieldNumbers []int
+}
+
+// NewOneof creates a oneof with the given member field numbers.
+func NewOneof(fieldNumbers ...int) *Oneof {
+	return &Oneof{fieldNumbers: fieldNumbers}
+}
+
+// OneofFromDescriptor creates a oneof with the member fields of the descriptor.
+func OneofFromDescriptor(od protoreflect.OneofDescriptor) *Oneof {
+	fields := od.Fields()
+	o := &Oneof{fieldNumbers: make([]int, fields.Len())}
+	for i := 0; i < fields.Len(); i++ {
+		o.fieldNumbers[i] = int(fields.Get(i).Number())
+	}
+
+	return o
+}
+
+// FieldNumbers returns the member field numbers of the oneof.
+func (o *Oneof) FieldNumbers() []int {
+	return o.fieldNumbers
+}
+
+// Contains returns true if the field number is a member of the oneof.
+func (o *Oneof) Contains(fieldNumber int) bool {
+	for _, n := range o.fieldNumbers {
+		if n == fieldNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Find scans the whole message and returns a scanner positioned
+// on the value of the member that is set. The member can be
+// found using FieldNumber() and read using the regular accessors.
+// Returns nil if none of the members are in the message.
+func (o *Oneof) Find(data []byte) (*Message, error) {
+	var (
+		found  bool
+		last   field
+		number int
+	)
+
+	err := scanFields(data, func(fieldNumber int, f field) {
+		if o.Contains(fieldNumber) {
+			found, last, number = true, f, fieldNumber
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return fieldScanner(data, number, last), nil
+}
+
+// Lookup is like Find but uses a message index instead of scanning.
+// Returns false if none of the members are in the message.
+func (o *Oneof) Lookup(idx *FieldIndex) (*Message, bool) {
+	var (
+		found  bool
+		last   field
+		number int
+	)
+
+	for _, n := range o.fieldNumbers {
+		fields := idx.fields[n]
+		if len(fields) == 0 {
+			continue
+		}
+
+		if f := fields[len(fields)-1]; !found || f.start > last.start {
+			found, last, number = true, f, n
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	return fieldScanner(idx.data, number, last), true
+}
+
+// Which returns the field number of the member
+// that is set or 0 if none of them are.
+func (o *Oneof) Which(data []byte) (int, error) {
+	msg, err := o.Find(data)
+	if err != nil || msg == nil {
+		return 0, err
+	}
+
+	return msg.fieldNumber, nil
+}
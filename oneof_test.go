@@ -0,0 +1,69 @@
+package pbr
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestOneof(t *testing.T) {
+	// google.protobuf.Value has a oneof for its kind
+	od := (&structpb.Value{}).ProtoReflect().Descriptor().Oneofs().ByName("kind")
+	oneof := OneofFromDescriptor(od)
+
+	if !oneof.Contains(3) || oneof.Contains(7) {
+		t.Errorf("incorrect members: %v", oneof.FieldNumbers())
+	}
+
+	data := []byte{
+		0x1a, 0x03, 'a', 'b', 'c', // string_value = "abc"
+		0x38, 0x01, // unknown field 7
+		0x20, 0x01, // bool_value = true
+		0x40, 0x01, // unknown field 8
+	}
+
+	t.Run("find", func(t *testing.T) {
+		msg, err := oneof.Find(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %e", err)
+		}
+
+		if msg.FieldNumber() != 4 {
+			t.Fatalf("incorrect member: %v", msg.FieldNumber())
+		}
+
+		if v, err := msg.Bool(); err != nil || !v {
+			t.Errorf("incorrect value: %v %e", v, err)
+		}
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		idx, err := Index(data)
+		if err != nil {
+			t.Fatalf("unable to index: %e", err)
+		}
+
+		msg, ok := NewOneof(3, 4).Lookup(idx)
+		if !ok || msg.FieldNumber() != 4 {
+			t.Errorf("incorrect member: %v", msg)
+		}
+
+		if _, ok := NewOneof(1, 2).Lookup(idx); ok {
+			t.Errorf("should not find a member")
+		}
+	})
+
+	t.Run("which", func(t *testing.T) {
+		if v, err := NewOneof(3).Which(data); err != nil || v != 3 {
+			t.Errorf("incorrect member: %v %e", v, err)
+		}
+
+		if v, err := NewOneof(5, 6).Which(data); err != nil || v != 0 {
+			t.Errorf("incorrect member: %v %e", v, err)
+		}
+
+		if _, err := oneof.Which([]byte{0x1a, 0x05}); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,258 @@
+package pbr
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelEach calls fn for every embedded message in the given field,
+// spreading the work over a pool of 'workers' goroutines.
+// The messages are found with a cheap scan of the top level message
+// before any of them are decoded. Zero or less workers will use GOMAXPROCS.
+// The Message passed to fn is reused by the worker and must not be retained.
+// The order fn is called in is undefined, use ParallelMap if order matters.
+// Returns the first error encountered, no new messages are
+// started after an error.
+func ParallelEach(data []byte, fieldNumber, workers int, fn func(*Message) error) error {
+	chunks, err := fieldChunks(data, fieldNumber)
+	if err != nil {
+		return err
+	}
+
+	msgs := make([]Message, parallelWorkers(workers, len(chunks)))
+	return parallel(len(msgs), len(chunks), func(w, i int) error {
+		msgs[w].Reset(chunks[i])
+		return fn(&msgs[w])
+	})
+}
+
+// ParallelMap is like ParallelEach but collects the
+// values returned by fn in the order of the messages.
+func ParallelMap[T any](data []byte, fieldNumber, workers int, fn func(*Message) (T, error)) ([]T, error) {
+	chunks, err := fieldChunks(data, fieldNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]T, len(chunks))
+	msgs := make([]Message, parallelWorkers(workers, len(chunks)))
+	err = parallel(len(msgs), len(chunks), func(w, i int) (err error) {
+		msgs[w].Reset(chunks[i])
+		result[i], err = fn(&msgs[w])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ParallelPacked splits the values of a packed repeated field into
+// chunks and calls fn with an iterator for each of them,
+// spreading the work over a pool of 'workers' goroutines.
+// The wireType of the values must be given, see Iterator.Count.
+// Values that are not packed are batched into chunks as well.
+// Varint values are split after their terminating byte
+// so no value is shared between two chunks.
+// The Iterator passed to fn is reused by the worker and must not be retained.
+// The index argument is the position of the chunk, chunks are in the order of the values.
+func ParallelPacked(data []byte, fieldNumber, wireType, workers int, fn func(index int, iter *Iterator) error) error {
+	fields, err := packedChunks(data, fieldNumber, wireType)
+	if err != nil {
+		return err
+	}
+
+	var size int
+	for _, f := range fields {
+		size += len(f)
+	}
+
+	workers = parallelWorkers(workers, size)
+	if workers == 0 {
+		return nil
+	}
+
+	var chunks [][]byte
+	for _, f := range fields {
+		c, err := splitPacked(f, wireType, (len(f)*workers+size-1)/size)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, c...)
+	}
+
+	iters := make([]Iterator, workers)
+	return parallel(workers, len(chunks), func(w, i int) error {
+		iters[w] = Iterator{
+			base:        base{Data: chunks[i]},
+			fieldNumber: fieldNumber,
+		}
+		return fn(i, &iters[w])
+	})
+}
+
+// fieldChunks returns the data of all the
+// length-delimited occurrences of the field.
+func fieldChunks(data []byte, fieldNumber int) ([][]byte, error) {
+	var chunks [][]byte
+	msg := New(data)
+	for msg.Next() {
+		if msg.fieldNumber != fieldNumber || msg.wireType != WireTypeLengthDelimited {
+			msg.Skip()
+			continue
+		}
+
+		d, err := msg.MessageData()
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, d)
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	return chunks, nil
+}
+
+// packedChunks returns the packed data of all the occurrences of the field,
+// consecutive unpacked values of the wire type are joined into one chunk.
+func packedChunks(data []byte, fieldNumber, wireType int) ([][]byte, error) {
+	var (
+		chunks   [][]byte
+		unpacked []byte
+	)
+	msg := New(data)
+	for msg.Next() {
+		if msg.fieldNumber != fieldNumber {
+			msg.Skip()
+			continue
+		}
+
+		switch msg.wireType {
+		case WireTypeLengthDelimited:
+			d, err := msg.MessageData()
+			if err != nil {
+				return nil, err
+			}
+
+			if len(unpacked) > 0 {
+				chunks = append(chunks, unpacked)
+				unpacked = nil
+			}
+			chunks = append(chunks, d)
+		case wireType:
+			start := msg.Index
+			msg.Skip()
+			if msg.err != nil {
+				return nil, msg.err
+			}
+			unpacked = append(unpacked, data[start:msg.Index]...)
+		default:
+			return nil, ErrInvalidWireType
+		}
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	if len(unpacked) > 0 {
+		chunks = append(chunks, unpacked)
+	}
+
+	return chunks, nil
+}
+
+// splitPacked splits packed data into about n chunks on value boundaries.
+func splitPacked(data []byte, wireType, n int) ([][]byte, error) {
+	var size int
+	switch wireType {
+	case WireTypeVarint:
+		size = 1
+	case WireType32bit:
+		size = 4
+	case WireType64bit:
+		size = 8
+	default:
+		return nil, ErrInvalidWireType
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	chunkLen := (len(data)/size + n - 1) / n * size
+	if chunkLen == 0 {
+		chunkLen = size
+	}
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		end := chunkLen
+		if end > len(data) {
+			end = len(data)
+		}
+
+		if wireType == WireTypeVarint {
+			// move the split point past the end of the current varint
+			for end < len(data) && data[end-1] >= 128 {
+				end++
+			}
+		}
+
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+
+	return chunks, nil
+}
+
+func parallelWorkers(workers, jobs int) int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers > jobs {
+		workers = jobs
+	}
+
+	return workers
+}
+
+// parallel runs fn for each job index using the given number of workers.
+// The first error is returned and stops any new jobs from being started.
+func parallel(workers, jobs int, fn func(worker, job int) error) error {
+	var (
+		next    atomic.Int64
+		failed  atomic.Bool
+		errOnce sync.Once
+		err     error
+		wg      sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= jobs {
+					return
+				}
+
+				if e := fn(w, i); e != nil {
+					errOnce.Do(func() { err = e })
+					failed.Store(true)
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	return err
+}
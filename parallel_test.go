@@ -0,0 +1,180 @@
+package pbr
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParallelEach(t *testing.T) {
+	c := &testmsg.Customer{Id: 1}
+	for i := 0; i < 100; i++ {
+		c.Orders = append(c.Orders, &testmsg.Order{Id: int64(i), Open: i%2 == 0})
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	var open atomic.Int64
+	err = ParallelEach(data, 3, 4, func(order *Message) error {
+		for order.Next() {
+			if order.FieldNumber() != 2 {
+				order.Skip()
+				continue
+			}
+
+			v, err := order.Bool()
+			if err != nil {
+				return err
+			}
+
+			if v {
+				open.Add(1)
+			}
+		}
+		return order.Error()
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	if v := open.Load(); v != 50 {
+		t.Errorf("incorrect count: %v", v)
+	}
+
+	ids, err := ParallelMap(data, 3, 0, func(order *Message) (int64, error) {
+		for order.Next() {
+			if order.FieldNumber() == 1 {
+				return order.Int64()
+			}
+			order.Skip()
+		}
+		return 0, order.Error()
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	if len(ids) != 100 {
+		t.Fatalf("incorrect length: %v", len(ids))
+	}
+
+	for i, id := range ids {
+		if id != int64(i) {
+			t.Fatalf("order not preserved at %d: %v", i, id)
+		}
+	}
+
+	errFailed := errors.New("failed")
+	err = ParallelEach(data, 3, 4, func(order *Message) error {
+		return errFailed
+	})
+	if err != errFailed {
+		t.Errorf("incorrect error: %v", err)
+	}
+}
+
+func TestParallelPacked(t *testing.T) {
+	message := &testmsg.Packed{}
+	var expected int64
+	for i := 0; i < 1000; i++ {
+		message.I64 = append(message.I64, int64(i*i))
+		message.F64 = append(message.F64, uint64(i))
+		expected += int64(i * i)
+	}
+
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	sums := make([]int64, 16)
+	err = ParallelPacked(data, 4, WireTypeVarint, 4, func(index int, iter *Iterator) error {
+		for iter.HasNext() {
+			v, err := iter.Int64()
+			if err != nil {
+				return err
+			}
+			sums[index] += v
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	var total int64
+	for _, s := range sums {
+		total += s
+	}
+
+	if total != expected {
+		t.Errorf("incorrect sum: %v != %v", total, expected)
+	}
+
+	var count atomic.Int64
+	err = ParallelPacked(data, 10, WireType64bit, 3, func(index int, iter *Iterator) error {
+		count.Add(int64(iter.Count(WireType64bit)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	if v := count.Load(); v != 1000 {
+		t.Errorf("incorrect count: %v", v)
+	}
+
+	err = ParallelPacked(data, 4, WireTypeLengthDelimited, 3, func(int, *Iterator) error { return nil })
+	if err != ErrInvalidWireType {
+		t.Errorf("incorrect error: %v", err)
+	}
+
+	// unpacked values, also mixed with packed ones
+	var unpacked []byte
+	for _, v := range []uint64{1, 300, 2} {
+		unpacked = appendTag(unpacked, 4, WireTypeVarint)
+		unpacked = appendVarint(unpacked, v)
+	}
+	unpacked = appendBytes(unpacked, 4, []byte{0x03, 0x04})
+	unpacked = appendTag(unpacked, 4, WireTypeVarint)
+	unpacked = appendVarint(unpacked, 5)
+
+	var (
+		mu     sync.Mutex
+		values []int64
+	)
+	err = ParallelPacked(unpacked, 4, WireTypeVarint, 2, func(index int, iter *Iterator) error {
+		for iter.HasNext() {
+			v, err := iter.Int64()
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			values = append(values, v)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	slices.Sort(values)
+	if !slices.Equal(values, []int64{1, 2, 3, 4, 5, 300}) {
+		t.Errorf("incorrect values: %v", values)
+	}
+
+	err = ParallelPacked(unpacked, 4, WireType64bit, 2, func(int, *Iterator) error { return nil })
+	if err != ErrInvalidWireType {
+		t.Errorf("incorrect error: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package pbr
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// UnmarshalFields decodes only the fields with the numbers into the message,
+// everything else is skipped on the wire level without being decoded.
+// The selected fields are copied into a new buffer which is then decoded
+// with proto.Unmarshal, so the message is reset first,
+// and required fields that are not selected are not checked.
+func UnmarshalFields(data []byte, m proto.Message, fieldNumbers ...int) error {
+	paths := make([]Path, 0, len(fieldNumbers))
+	for _, n := range fieldNumbers {
+		paths = append(paths, Path{n})
+	}
+
+	return unmarshalPaths(data, m, newPathTree(paths))
+}
+
+// UnmarshalMasked decodes only the fields in the mask into the message,
+// see UnmarshalFields. Paths can select fields of embedded messages,
+// e.g. 'orders.id' leaves every order with only the id set.
+func UnmarshalMasked(data []byte, m proto.Message, mask *fieldmaskpb.FieldMask) error {
+	tree, err := maskTree(m.ProtoReflect().Descriptor(), mask)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalPaths(data, m, tree)
+}
+
+// unmarshalPaths filters the data with selectPaths, re-encoding embedded
+// messages on the paths, and unmarshals the filtered copy.
+// The copy costs an allocation of the size of the selected fields.
+func unmarshalPaths(data []byte, m proto.Message, tree pathTree) error {
+	selected, err := selectPaths(nil, data, tree)
+	if err != nil {
+		return err
+	}
+
+	return proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(selected, m)
+}
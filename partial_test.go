@@ -0,0 +1,120 @@
+package pbr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestUnmarshalFields(t *testing.T) {
+	data, err := proto.Marshal(&testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 2, Open: true}, {Id: 3}},
+		FavoriteIds: []int64{4, 5},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	c := &testmsg.Customer{Username: "reset"}
+	if err := UnmarshalFields(data, c, 1, 4); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := &testmsg.Customer{Id: 1, FavoriteIds: []int64{4, 5}}
+	if !proto.Equal(c, expected) {
+		t.Errorf("incorrect message: %v", c)
+	}
+
+	if err := UnmarshalFields(data, c); err != nil || !proto.Equal(c, &testmsg.Customer{}) {
+		t.Errorf("no fields should be selected: %v %e", c, err)
+	}
+}
+
+func TestUnmarshalMasked(t *testing.T) {
+	data, err := proto.Marshal(&testmsg.Customer{
+		Id:       1,
+		Username: "name",
+		Orders:   []*testmsg.Order{{Id: 2, Open: true}, {Id: 3, Items: []*testmsg.Item{{Id: 4}}}},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	c := &testmsg.Customer{}
+	if err := UnmarshalMasked(data, c, &fieldmaskpb.FieldMask{Paths: []string{"username", "orders.id"}}); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := &testmsg.Customer{
+		Username: "name",
+		Orders:   []*testmsg.Order{{Id: 2}, {Id: 3}},
+	}
+	if !proto.Equal(c, expected) {
+		t.Errorf("incorrect message: %v", c)
+	}
+
+	if err := UnmarshalMasked(data, c, &fieldmaskpb.FieldMask{Paths: []string{"missing"}}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("unknown field should fail: %v", err)
+	}
+}
+
+func TestUnmarshalFields_required(t *testing.T) {
+	data := marshalPartial(t, newLegacy(t, "Customer", `id: 1 username: "name" orders { id: 2 open: true }`))
+
+	m := newLegacy(t, "Customer", ``)
+	if err := UnmarshalFields(data, m, 2); err != nil {
+		t.Fatalf("missing required fields should be allowed: %e", err)
+	}
+
+	if !proto.Equal(m, newLegacy(t, "Customer", `username: "name"`)) {
+		t.Errorf("incorrect message: %v", m)
+	}
+}
+
+func BenchmarkUnmarshalFields(b *testing.B) {
+	c := &testmsg.Customer{Id: 1, Username: "name"}
+	for i := 0; i < 1000; i++ {
+		c.Orders = append(c.Orders, &testmsg.Order{Id: int64(i), Open: true, Items: []*testmsg.Item{{Id: 1}, {Id: 2}}})
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		b.Fatalf("unable to marshal: %e", err)
+	}
+
+	// includes the copy of the selected fields, measured alone by select
+	b.Run("pbr", func(b *testing.B) {
+		b.ReportAllocs()
+		m := &testmsg.Customer{}
+		for i := 0; i < b.N; i++ {
+			if err := UnmarshalFields(data, m, 1, 2); err != nil {
+				b.Fatalf("unable to unmarshal: %e", err)
+			}
+		}
+	})
+
+	b.Run("select", func(b *testing.B) {
+		b.ReportAllocs()
+		tree := newPathTree([]Path{{1}, {2}})
+		for i := 0; i < b.N; i++ {
+			if _, err := selectPaths(nil, data, tree); err != nil {
+				b.Fatalf("unable to select: %e", err)
+			}
+		}
+	})
+
+	b.Run("proto", func(b *testing.B) {
+		b.ReportAllocs()
+		m := &testmsg.Customer{}
+		for i := 0; i < b.N; i++ {
+			if err := proto.Unmarshal(data, m); err != nil {
+				b.Fatalf("unable to unmarshal: %e", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package pbr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrInvalidPath is returned when a field path can't be parsed
+// or doesn't match the message descriptor.
+var ErrInvalidPath = errors.New("protoscan: invalid field path")
+
+// Path is the list of field numbers from the root message to a field,
+// every field except the last one is an embedded message.
+type Path []int
+
+// ParsePath parses a path of dot separated field numbers or,
+// if the message descriptor is given, field names, e.g. 'orders.id' or '3.1'.
+func ParsePath(s string, md protoreflect.MessageDescriptor) (Path, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+
+	parts := strings.Split(s, ".")
+	path := make(Path, 0, len(parts))
+	for i, part := range parts {
+		var fd protoreflect.FieldDescriptor
+		if n, err := strconv.Atoi(part); err == nil {
+			if n <= 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidPath, s)
+			}
+
+			if md != nil {
+				fd = md.Fields().ByNumber(protoreflect.FieldNumber(n))
+			}
+			path = append(path, n)
+		} else {
+			if md == nil {
+				return nil, fmt.Errorf("%w: %q, field names require a descriptor", ErrInvalidPath, s)
+			}
+
+			fd = md.Fields().ByName(protoreflect.Name(part))
+			if fd == nil {
+				return nil, fmt.Errorf("%w: %q, unknown field %s in %s", ErrInvalidPath, s, part, md.FullName())
+			}
+			path = append(path, int(fd.Number()))
+		}
+
+		if md == nil || i == len(parts)-1 {
+			continue
+		}
+
+		if fd == nil || fd.Message() == nil {
+			return nil, fmt.Errorf("%w: %q, %s is not a message", ErrInvalidPath, s, part)
+		}
+		md = fd.Message()
+	}
+
+	return path, nil
+}
+
+// String returns the path as dot separated field numbers.
+func (p Path) String() string {
+	parts := make([]string, len(p))
+	for i, n := range p {
+		parts[i] = strconv.Itoa(n)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// pathTree is a set of paths, a node with no children selects
+// the whole field.
+type pathTree map[int]pathTree
+
+// newPathTree builds a tree of the paths,
+// a path selects everything below it even if longer paths are added.
+func newPathTree(paths []Path) pathTree {
+	tree := pathTree{}
+	for _, p := range paths {
+		node := tree
+		for i, n := range p {
+			child, ok := node[n]
+			if ok && len(child) == 0 {
+				break // already selected
+			}
+
+			if i == len(p)-1 {
+				node[n] = pathTree{}
+				break
+			}
+
+			if !ok {
+				child = pathTree{}
+				node[n] = child
+			}
+			node = child
+		}
+	}
+
+	return tree
+}
@@ -0,0 +1,63 @@
+package pbr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+
+	cases := []struct {
+		name     string
+		path     string
+		expected Path
+	}{
+		{name: "names", path: "orders.items.id", expected: Path{3, 3, 1}},
+		{name: "numbers", path: "3.3.1", expected: Path{3, 3, 1}},
+		{name: "mixed", path: "last.2", expected: Path{5, 2}},
+		{name: "map", path: "by_name.value.id", expected: Path{6, 2, 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParsePath(tc.path, md)
+			if err != nil {
+				t.Fatalf("unable to parse: %e", err)
+			}
+
+			if !reflect.DeepEqual(p, tc.expected) {
+				t.Errorf("incorrect path: %v != %v", p, tc.expected)
+			}
+		})
+	}
+
+	p, err := ParsePath("1.20.3", nil)
+	if err != nil || p.String() != "1.20.3" {
+		t.Errorf("incorrect path: %v %e", p, err)
+	}
+
+	for _, invalid := range []string{"", "id.1", "orders.missing", "0", "3..1"} {
+		if _, err := ParsePath(invalid, md); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%q should be invalid: %v", invalid, err)
+		}
+	}
+
+	if _, err := ParsePath("orders", nil); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("names should require a descriptor: %v", err)
+	}
+}
+
+func TestNewPathTree(t *testing.T) {
+	tree := newPathTree([]Path{{3, 1}, {3, 2}, {5, 1}, {5}, {1}, {1, 2}})
+	expected := pathTree{
+		1: {},
+		3: {1: {}, 2: {}},
+		5: {},
+	}
+
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("incorrect tree: %v", tree)
+	}
+}
@@ -0,0 +1,257 @@
+package pbr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrInvalidTag is returned when the pbr struct tags of a type are invalid.
+var ErrInvalidTag = errors.New("protoscan: invalid struct tag")
+
+// structPlan is how a struct type is encoded, compiled once from the pbr tags.
+// Fields are tagged with the field number followed by options:
+//
+//	ID     int64            `pbr:"1"`
+//	Delta  int64            `pbr:"3,sint64"`
+//	Hashes []uint64         `pbr:"4,packed,fixed64"`
+//	Tags   map[string]int32 `pbr:"5,key=string,sint32"`
+//	Child  *Child           `pbr:"6"`
+//
+// The kind is inferred from the Go type if not given, see plannedKinds.
+// For slices and maps the kind is for the element or the value.
+type structPlan struct {
+	fields  []*fieldPlan // ordered by number
+	numbers map[int]*fieldPlan
+}
+
+// fieldPlan is how a struct field is encoded.
+type fieldPlan struct {
+	name     string
+	number   int
+	index    int
+	kind     protoreflect.Kind // of the value, the element or the map value
+	keyKind  protoreflect.Kind // of the map key
+	packed   bool
+	unpacked bool
+	repeated bool
+	isMap    bool
+	elem     reflect.Type // type of the value, the element or the map value
+	message  *structPlan  // for struct and pointer to struct values
+	pointer  bool         // the message value is a pointer
+}
+
+// plannedKinds are the kinds allowed in tags by name and their Go types.
+var plannedKinds = map[string]struct {
+	kind  protoreflect.Kind
+	types []reflect.Kind
+}{
+	"bool":     {protoreflect.BoolKind, []reflect.Kind{reflect.Bool}},
+	"enum":     {protoreflect.EnumKind, []reflect.Kind{reflect.Int32, reflect.Int, reflect.Int64}},
+	"int32":    {protoreflect.Int32Kind, []reflect.Kind{reflect.Int32, reflect.Int, reflect.Int64}},
+	"sint32":   {protoreflect.Sint32Kind, []reflect.Kind{reflect.Int32, reflect.Int, reflect.Int64}},
+	"sfixed32": {protoreflect.Sfixed32Kind, []reflect.Kind{reflect.Int32, reflect.Int, reflect.Int64}},
+	"uint32":   {protoreflect.Uint32Kind, []reflect.Kind{reflect.Uint32, reflect.Uint, reflect.Uint64}},
+	"fixed32":  {protoreflect.Fixed32Kind, []reflect.Kind{reflect.Uint32, reflect.Uint, reflect.Uint64}},
+	"int64":    {protoreflect.Int64Kind, []reflect.Kind{reflect.Int64, reflect.Int}},
+	"sint64":   {protoreflect.Sint64Kind, []reflect.Kind{reflect.Int64, reflect.Int}},
+	"sfixed64": {protoreflect.Sfixed64Kind, []reflect.Kind{reflect.Int64, reflect.Int}},
+	"uint64":   {protoreflect.Uint64Kind, []reflect.Kind{reflect.Uint64, reflect.Uint}},
+	"fixed64":  {protoreflect.Fixed64Kind, []reflect.Kind{reflect.Uint64, reflect.Uint}},
+	"float":    {protoreflect.FloatKind, []reflect.Kind{reflect.Float32, reflect.Float64}},
+	"double":   {protoreflect.DoubleKind, []reflect.Kind{reflect.Float64, reflect.Float32}},
+	"string":   {protoreflect.StringKind, []reflect.Kind{reflect.String}},
+	"bytes":    {protoreflect.BytesKind, []reflect.Kind{reflect.Slice}},
+}
+
+// maxFieldNumber is the largest valid field number.
+const maxFieldNumber = 1<<29 - 1
+
+var plans sync.Map // reflect.Type -> *structPlan
+
+// planFor returns the cached plan of the struct type.
+func planFor(t reflect.Type) (*structPlan, error) {
+	if p, ok := plans.Load(t); ok {
+		return p.(*structPlan), nil
+	}
+
+	// plans of recursive types are built together
+	building := make(map[reflect.Type]*structPlan)
+	p, err := compilePlan(t, building)
+	if err != nil {
+		return nil, err
+	}
+
+	for t, p := range building {
+		plans.LoadOrStore(t, p)
+	}
+
+	return p, nil
+}
+
+func compilePlan(t reflect.Type, building map[reflect.Type]*structPlan) (*structPlan, error) {
+	if p, ok := plans.Load(t); ok {
+		return p.(*structPlan), nil
+	}
+
+	if p, ok := building[t]; ok {
+		return p, nil
+	}
+
+	p := &structPlan{numbers: make(map[int]*fieldPlan)}
+	building[t] = p
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("pbr")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("%w: %s.%s is not exported", ErrInvalidTag, t, sf.Name)
+		}
+
+		f, err := compileField(sf, tag, building)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrInvalidTag, t, sf.Name, err)
+		}
+		f.index = i
+
+		if _, ok := p.numbers[f.number]; ok {
+			return nil, fmt.Errorf("%w: %s.%s: duplicate field number %d", ErrInvalidTag, t, sf.Name, f.number)
+		}
+		p.numbers[f.number] = f
+		p.fields = append(p.fields, f)
+	}
+
+	slices.SortFunc(p.fields, func(a, b *fieldPlan) int {
+		return a.number - b.number
+	})
+
+	return p, nil
+}
+
+func compileField(sf reflect.StructField, tag string, building map[reflect.Type]*structPlan) (*fieldPlan, error) {
+	parts := strings.Split(tag, ",")
+	number, err := strconv.Atoi(parts[0])
+	if err != nil || number <= 0 || number > maxFieldNumber {
+		return nil, fmt.Errorf("invalid field number %q", parts[0])
+	}
+
+	f := &fieldPlan{
+		name:   sf.Name,
+		number: number,
+		elem:   sf.Type,
+	}
+
+	var kind, key string
+	for _, opt := range parts[1:] {
+		switch {
+		case opt == "packed":
+			f.packed = true
+		case opt == "unpacked":
+			f.unpacked = true
+		case strings.HasPrefix(opt, "key="):
+			key = strings.TrimPrefix(opt, "key=")
+		case kind == "":
+			kind = opt
+		default:
+			return nil, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+
+	t := sf.Type
+	switch {
+	case t.Kind() == reflect.Map:
+		f.isMap = true
+		f.elem = t.Elem()
+		if f.keyKind, err = planKind(t.Key(), key); err != nil {
+			return nil, err
+		}
+
+		if f.keyKind == protoreflect.BytesKind || f.keyKind == protoreflect.FloatKind || f.keyKind == protoreflect.DoubleKind {
+			return nil, fmt.Errorf("invalid map key kind %v", f.keyKind)
+		}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		f.repeated = true
+		f.elem = t.Elem()
+	case key != "":
+		return nil, fmt.Errorf("key kind for a non map field")
+	}
+
+	if f.packed && f.unpacked {
+		return nil, fmt.Errorf("packed and unpacked")
+	}
+
+	elem := f.elem
+	if elem.Kind() == reflect.Pointer && elem.Elem().Kind() == reflect.Struct {
+		f.pointer = true
+		elem = elem.Elem()
+	}
+
+	if elem.Kind() == reflect.Struct {
+		if kind != "" && kind != "message" {
+			return nil, fmt.Errorf("kind %q for a struct", kind)
+		}
+
+		f.kind = protoreflect.MessageKind
+		if f.message, err = compilePlan(elem, building); err != nil {
+			return nil, err
+		}
+	} else if f.kind, err = planKind(f.elem, kind); err != nil {
+		return nil, err
+	}
+
+	if (f.packed || f.unpacked) && (!f.repeated || kindWireType(f.kind) == WireTypeLengthDelimited) {
+		return nil, fmt.Errorf("only repeated scalars can be packed")
+	}
+
+	return f, nil
+}
+
+// planKind returns the named kind, or the default kind of the Go type,
+// and checks that they match.
+func planKind(t reflect.Type, name string) (protoreflect.Kind, error) {
+	if name == "" {
+		switch t.Kind() {
+		case reflect.Bool:
+			name = "bool"
+		case reflect.Int32:
+			name = "int32"
+		case reflect.Int, reflect.Int64:
+			name = "int64"
+		case reflect.Uint32:
+			name = "uint32"
+		case reflect.Uint, reflect.Uint64:
+			name = "uint64"
+		case reflect.Float32:
+			name = "float"
+		case reflect.Float64:
+			name = "double"
+		case reflect.String:
+			name = "string"
+		case reflect.Slice:
+			name = "bytes"
+		default:
+			return 0, fmt.Errorf("unsupported type %v", t)
+		}
+	}
+
+	k, ok := plannedKinds[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown kind %q", name)
+	}
+
+	if !slices.Contains(k.types, t.Kind()) || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) {
+		return 0, fmt.Errorf("kind %s for type %v", name, t)
+	}
+
+	return k.kind, nil
+}
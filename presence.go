@@ -0,0 +1,142 @@
+package pbr
+
+import (
+	"math/bits"
+	"sort"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldSetBits is the number of field numbers kept in the bitset,
+// larger field numbers are rare and are kept in a map.
+const fieldSetBits = 1 << 12
+
+// FieldSet is a set of field numbers, used to track which fields
+// were present in a message.
+// The zero value is an empty set ready to use.
+type FieldSet struct {
+	bits  []uint64
+	large map[int]struct{}
+}
+
+// Presence scans the message and returns the set
+// of field numbers that are present.
+func Presence(data []byte) (*FieldSet, error) {
+	set := &FieldSet{}
+	msg := New(data)
+	msg.Track(set)
+	for msg.Next() {
+		msg.Skip()
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	return set, nil
+}
+
+// Track will add the number of every field
+// read using Next to the set.
+// Tracking stays enabled after Reset and is disabled by passing nil.
+func (m *Message) Track(set *FieldSet) {
+	m.seen = set
+}
+
+// Add adds the field number to the set.
+func (s *FieldSet) Add(fieldNumber int) {
+	if fieldNumber < 0 {
+		return
+	}
+
+	if fieldNumber >= fieldSetBits {
+		if s.large == nil {
+			s.large = make(map[int]struct{})
+		}
+		s.large[fieldNumber] = struct{}{}
+		return
+	}
+
+	i := fieldNumber / 64
+	if i >= len(s.bits) {
+		s.bits = append(s.bits, make([]uint64, i+1-len(s.bits))...)
+	}
+	s.bits[i] |= 1 << (fieldNumber % 64)
+}
+
+// Has returns true if the field number is in the set.
+func (s *FieldSet) Has(fieldNumber int) bool {
+	if fieldNumber < 0 {
+		return false
+	}
+
+	if fieldNumber >= fieldSetBits {
+		_, ok := s.large[fieldNumber]
+		return ok
+	}
+
+	i := fieldNumber / 64
+	return i < len(s.bits) && s.bits[i]&(1<<(fieldNumber%64)) != 0
+}
+
+// Len returns the number of field numbers in the set.
+func (s *FieldSet) Len() int {
+	n := len(s.large)
+	for _, b := range s.bits {
+		n += bits.OnesCount64(b)
+	}
+
+	return n
+}
+
+// FieldNumbers returns the field numbers in the set in increasing order.
+func (s *FieldSet) FieldNumbers() []int {
+	result := make([]int, 0, s.Len())
+	for i, b := range s.bits {
+		for b != 0 {
+			result = append(result, i*64+bits.TrailingZeros64(b))
+			b &= b - 1
+		}
+	}
+
+	large := make([]int, 0, len(s.large))
+	for n := range s.large {
+		large = append(large, n)
+	}
+	sort.Ints(large)
+
+	return append(result, large...)
+}
+
+// Reset removes all the field numbers from the set.
+func (s *FieldSet) Reset() {
+	clear(s.bits)
+	clear(s.large)
+}
+
+// Default returns the value of a field that is not in the message:
+// the declared proto2 default or the proto3 zero value.
+// Message, repeated and map fields return an invalid value.
+// The boolean reports if the field has explicit presence,
+// i.e. proto2 fields, proto3 optional fields, messages and oneof members,
+// so that an absent field is different from one set to the default.
+func Default(fd protoreflect.FieldDescriptor) (protoreflect.Value, bool) {
+	if fd.IsList() || fd.IsMap() || fd.Message() != nil {
+		return protoreflect.Value{}, fd.HasPresence()
+	}
+
+	return fd.Default(), fd.HasPresence()
+}
+
+// Absent returns the fields of the descriptor that are not in the set.
+func Absent(md protoreflect.MessageDescriptor, set *FieldSet) []protoreflect.FieldDescriptor {
+	var result []protoreflect.FieldDescriptor
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); !set.Has(int(fd.Number())) {
+			result = append(result, fd)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,119 @@
+package pbr
+
+import (
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestFieldSet(t *testing.T) {
+	set := &FieldSet{}
+	for _, n := range []int{5000, 1, 64, 63, 1 << 29} {
+		set.Add(n)
+	}
+
+	for _, n := range []int{1, 63, 64, 5000, 1 << 29} {
+		if !set.Has(n) {
+			t.Errorf("should have %d", n)
+		}
+	}
+
+	for _, n := range []int{-1, 0, 2, 65, 4999, 100000} {
+		if set.Has(n) {
+			t.Errorf("should not have %d", n)
+		}
+	}
+
+	if v := set.FieldNumbers(); len(v) != 5 || v[0] != 1 || v[2] != 64 || v[4] != 1<<29 {
+		t.Errorf("incorrect field numbers: %v", v)
+	}
+
+	set.Reset()
+	if set.Len() != 0 || set.Has(1) || set.Has(5000) {
+		t.Errorf("set should be empty")
+	}
+}
+
+func TestMessage_Track(t *testing.T) {
+	data, err := proto.Marshal(&testmsg.Scalar{I64: 5, Str: "abc", After: true})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	set, err := Presence(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %e", err)
+	}
+
+	if v := set.FieldNumbers(); len(v) != 3 || v[0] != 4 || v[1] != 14 || v[2] != 32 {
+		t.Errorf("incorrect field numbers: %v", v)
+	}
+
+	md := (&testmsg.Scalar{}).ProtoReflect().Descriptor()
+	absent := Absent(md, set)
+	if len(absent) != 13 {
+		t.Errorf("incorrect number of absent fields: %v", len(absent))
+	}
+
+	for _, fd := range absent {
+		v, explicit := Default(fd)
+		if explicit {
+			t.Errorf("proto3 scalar should not have presence: %v", fd.Name())
+		}
+
+		if !v.IsValid() || !v.Equal(fd.Default()) {
+			t.Errorf("incorrect default for %v: %v", fd.Name(), v)
+		}
+	}
+
+	if _, err := Presence([]byte{0x0a, 0x05}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	// proto2 declared defaults
+	md := (&descriptorpb.FieldOptions{}).ProtoReflect().Descriptor()
+	v, explicit := Default(md.Fields().ByName("ctype"))
+	if !explicit || v.Enum() != descriptorpb.FieldOptions_STRING.Number() {
+		t.Errorf("incorrect default: %v %v", v, explicit)
+	}
+
+	v, explicit = Default(md.Fields().ByName("deprecated"))
+	if !explicit || v.Bool() {
+		t.Errorf("incorrect default: %v %v", v, explicit)
+	}
+
+	if v, _ := Default(md.Fields().ByName("uninterpreted_option")); v.IsValid() {
+		t.Errorf("repeated fields should not have a default: %v", v)
+	}
+
+	// proto3 optional
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:   proto.String("optional.proto"),
+		Syntax: proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("M"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:           proto.String("v"),
+				Number:         proto.Int32(1),
+				Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:           descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+				OneofIndex:     proto.Int32(0),
+				Proto3Optional: proto.Bool(true),
+			}},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_v")}},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to create descriptor: %e", err)
+	}
+
+	v, explicit = Default(fd.Messages().Get(0).Fields().Get(0))
+	if !explicit || v.Int() != 0 {
+		t.Errorf("incorrect default: %v %v", v, explicit)
+	}
+}
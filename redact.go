@@ -0,0 +1,269 @@
+package pbr
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// RedactAction is what a Redactor does with a selected field.
+type RedactAction int
+
+const (
+	// RedactDrop removes the field.
+	RedactDrop RedactAction = iota
+	// RedactZero keeps the field with the zero value of its wire type,
+	// an empty string or message for length-delimited fields.
+	RedactZero
+	// RedactHash replaces the value with its hash, meant for string and
+	// bytes fields. Other wire types get the hash truncated to their size.
+	// With a descriptor, strings get the hash in hex so they are valid UTF-8,
+	// embedded messages and packed fields are zeroed as a hash would not decode.
+	RedactHash
+)
+
+// Redactor copies messages while dropping, zeroing or hashing selected fields.
+// Every other byte is copied unchanged, embedded messages are only
+// re-encoded if they contain a redacted field.
+type Redactor struct {
+	// Hash is used by RedactHash, defaults to sha256.
+	Hash func() hash.Hash
+	// Descriptor is the type of the messages, optional for NewRedactor.
+	// RedactHash needs it to know the length-delimited fields
+	// that are not strings or bytes.
+	Descriptor protoreflect.MessageDescriptor
+
+	root   *redactRule
+	debug  bool // the fields with the debug_redact option are redacted
+	action RedactAction
+}
+
+// redactRule is a node in the tree of redacted paths.
+type redactRule struct {
+	action RedactAction
+	leaf   bool
+	fields map[int]*redactRule
+}
+
+// NewRedactor returns a redactor that applies the action to the fields at the paths.
+// Paths apply to every occurrence of repeated embedded messages.
+func NewRedactor(action RedactAction, paths ...Path) *Redactor {
+	return (&Redactor{}).Add(action, paths...)
+}
+
+// RedactorFromDescriptor returns a redactor that applies the action to every
+// field with the debug_redact option in the message and its embedded messages.
+func RedactorFromDescriptor(md protoreflect.MessageDescriptor, action RedactAction) *Redactor {
+	return &Redactor{
+		Descriptor: md,
+		debug:      true,
+		action:     action,
+	}
+}
+
+// Add applies the action to the fields at the paths,
+// replacing the previous action for the same or shorter paths.
+func (r *Redactor) Add(action RedactAction, paths ...Path) *Redactor {
+	if r.root == nil {
+		r.root = &redactRule{}
+	}
+
+	for _, p := range paths {
+		node := r.root
+		for _, n := range p {
+			if node.leaf {
+				break // an enclosing message is redacted
+			}
+
+			if node.fields == nil {
+				node.fields = make(map[int]*redactRule)
+			}
+
+			child, ok := node.fields[n]
+			if !ok {
+				child = &redactRule{}
+				node.fields[n] = child
+			}
+			node = child
+		}
+
+		if len(p) > 0 {
+			*node = redactRule{action: action, leaf: true}
+		}
+	}
+
+	return r
+}
+
+// Redact returns a copy of the message with the selected fields redacted.
+func (r *Redactor) Redact(data []byte) ([]byte, error) {
+	result, changed, err := r.message(nil, data, r.root, r.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		return append([]byte(nil), data...), nil
+	}
+
+	return result, nil
+}
+
+func (r *Redactor) message(buf, data []byte, rule *redactRule, md protoreflect.MessageDescriptor) ([]byte, bool, error) {
+	var (
+		changed bool
+		err     error
+	)
+	scanErr := scanFields(data, func(n int, f field) {
+		if err != nil {
+			return
+		}
+
+		var child *redactRule
+		if rule != nil {
+			child = rule.fields[n]
+		}
+
+		var fd protoreflect.FieldDescriptor
+		if md != nil {
+			fd = md.Fields().ByNumber(protoreflect.FieldNumber(n))
+		}
+
+		if child != nil && child.leaf {
+			changed = true
+			buf, err = r.redact(buf, data, n, f, fd, child.action)
+			return
+		}
+
+		if fd != nil && r.debug && debugRedact(fd) {
+			changed = true
+			buf, err = r.redact(buf, data, n, f, fd, r.action)
+			return
+		}
+
+		var sub protoreflect.MessageDescriptor
+		if fd != nil {
+			sub = fd.Message()
+		}
+
+		if child == nil && sub == nil {
+			buf = append(buf, data[f.start:f.end]...)
+			return
+		}
+
+		if f.wireType != WireTypeLengthDelimited {
+			if child != nil {
+				err = ErrInvalidWireType
+				return
+			}
+			buf = append(buf, data[f.start:f.end]...) // a group
+			return
+		}
+
+		payload, e := fieldScanner(data, n, f).MessageData()
+		if e != nil {
+			err = e
+			return
+		}
+
+		redacted, subChanged, e := r.message(nil, payload, child, sub)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !subChanged {
+			buf = append(buf, data[f.start:f.end]...)
+			return
+		}
+
+		changed = true
+		buf = append(buf, data[f.start:f.value]...)
+		buf = appendVarint(buf, uint64(len(redacted)))
+		buf = append(buf, redacted...)
+	})
+	if scanErr != nil {
+		return nil, false, scanErr
+	}
+
+	return buf, changed, err
+}
+
+// redact appends the field occurrence with the action applied,
+// fd is the field descriptor if known.
+func (r *Redactor) redact(buf, data []byte, n int, f field, fd protoreflect.FieldDescriptor, action RedactAction) ([]byte, error) {
+	if action == RedactHash && fd != nil && f.wireType == WireTypeLengthDelimited &&
+		(fd.Message() != nil || kindWireType(fd.Kind()) != WireTypeLengthDelimited) {
+		action = RedactZero // the hash would not decode
+	}
+
+	switch action {
+	case RedactDrop:
+		return buf, nil
+	case RedactZero:
+		buf = append(buf, data[f.start:f.value]...)
+		switch f.wireType {
+		case WireType32bit:
+			return append(buf, 0, 0, 0, 0), nil
+		case WireType64bit:
+			return append(buf, 0, 0, 0, 0, 0, 0, 0, 0), nil
+		}
+		return append(buf, 0), nil
+	}
+
+	newHash := r.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	h := newHash()
+
+	msg := fieldScanner(data, n, f)
+	buf = append(buf, data[f.start:f.value]...)
+	switch f.wireType {
+	case WireTypeLengthDelimited:
+		v, err := msg.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(v)
+
+		sum := h.Sum(nil)
+		if fd != nil && fd.Kind() == protoreflect.StringKind {
+			sum = []byte(hex.EncodeToString(sum))
+		}
+
+		buf = appendVarint(buf, uint64(len(sum)))
+		return append(buf, sum...), nil
+	case WireTypeVarint:
+		v, err := msg.Varint64()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(appendVarint(nil, v))
+
+		return appendVarint(buf, binary.LittleEndian.Uint64(paddedSum(h))), nil
+	case WireType32bit:
+		h.Write(data[f.value:f.end])
+		return append(buf, paddedSum(h)[:4]...), nil
+	case WireType64bit:
+		h.Write(data[f.value:f.end])
+		return append(buf, paddedSum(h)[:8]...), nil
+	}
+
+	return nil, ErrInvalidWireType
+}
+
+// debugRedact returns true if the field has the debug_redact option.
+func debugRedact(fd protoreflect.FieldDescriptor) bool {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && opts.GetDebugRedact()
+}
+
+// paddedSum returns the hash with at least 8 bytes.
+func paddedSum(h hash.Hash) []byte {
+	return append(h.Sum(nil), make([]byte, 8)...)
+}
@@ -0,0 +1,244 @@
+package pbr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestRedactor(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 1, Open: true}, {Id: 2, Items: []*testmsg.Item{{Id: 3}}}},
+		FavoriteIds: []int64{1, 2},
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	cases := []struct {
+		name     string
+		redactor *Redactor
+		expected *testmsg.Customer
+	}{
+		{
+			name:     "drop",
+			redactor: NewRedactor(RedactDrop, Path{2}, Path{3, 1}),
+			expected: &testmsg.Customer{
+				Id:          1,
+				Orders:      []*testmsg.Order{{Open: true}, {Items: []*testmsg.Item{{Id: 3}}}},
+				FavoriteIds: []int64{1, 2},
+			},
+		},
+		{
+			name:     "zero",
+			redactor: NewRedactor(RedactZero, Path{1}, Path{3, 3, 1}, Path{4}),
+			expected: &testmsg.Customer{
+				Username: "name",
+				Orders:   []*testmsg.Order{{Id: 1, Open: true}, {Id: 2, Items: []*testmsg.Item{{}}}},
+			},
+		},
+		{
+			name:     "mixed",
+			redactor: NewRedactor(RedactDrop, Path{3, 1}).Add(RedactDrop, Path{3}),
+			expected: &testmsg.Customer{Id: 1, Username: "name", FavoriteIds: []int64{1, 2}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			redacted, err := tc.redactor.Redact(data)
+			if err != nil {
+				t.Fatalf("unable to redact: %e", err)
+			}
+
+			result := &testmsg.Customer{}
+			if err := proto.Unmarshal(redacted, result); err != nil {
+				t.Fatalf("unable to unmarshal: %e", err)
+			}
+
+			if !proto.Equal(result, tc.expected) {
+				t.Errorf("incorrect message: %v", result)
+			}
+		})
+	}
+
+	redacted, err := NewRedactor(RedactHash, Path{2}).Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	idx, err := Index(redacted)
+	if err != nil {
+		t.Fatalf("unable to index: %e", err)
+	}
+
+	username, _ := idx.Lookup(2)
+	sum := sha256.Sum256([]byte("name"))
+	if v, err := username.Bytes(); err != nil || !bytes.Equal(v, sum[:]) {
+		t.Errorf("username should be hashed: %x %e", v, err)
+	}
+
+	if _, err := NewRedactor(RedactDrop, Path{1, 1}).Redact(data); !errors.Is(err, ErrInvalidWireType) {
+		t.Errorf("path through a scalar should fail: %v", err)
+	}
+}
+
+func TestRedactor_untouched(t *testing.T) {
+	// non-minimal lengths and varints should be kept when nothing changes
+	var data []byte
+	data = appendTag(data, 1, WireTypeVarint)
+	data = append(data, 0x81, 0x00)
+	data = appendTag(data, 3, WireTypeLengthDelimited)
+	data = append(data, 0x82, 0x00, 0x08, 0x01)
+	data = appendTag(data, 3, WireTypeLengthDelimited)
+	data = append(data, 0x84, 0x00, 0x08, 0x02, 0x10, 0x01)
+
+	redacted, err := NewRedactor(RedactDrop, Path{3, 2}).Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	expected := append([]byte(nil), data[:8]...)
+	expected = append(expected, 0x1a, 0x02, 0x08, 0x02)
+	if !bytes.Equal(redacted, expected) {
+		t.Errorf("incorrect data:\n%x\n%x", redacted, expected)
+	}
+
+	redacted, err = NewRedactor(RedactDrop, Path{2}).Redact(data)
+	if err != nil || !bytes.Equal(redacted, data) {
+		t.Errorf("data should be unchanged: %x %e", redacted, err)
+	}
+}
+
+func TestRedactor_hashScalars(t *testing.T) {
+	var data []byte
+	data = appendTag(data, 1, WireTypeVarint)
+	data = appendVarint(data, 150)
+	data = appendTag(data, 2, WireType32bit)
+	data = appendFixed32(data, 1)
+	data = appendTag(data, 3, WireType64bit)
+	data = appendFixed64(data, 2)
+
+	r := NewRedactor(RedactHash, Path{1}, Path{2}, Path{3})
+	r.Hash = func() hash.Hash { return crc32.NewIEEE() }
+
+	redacted, err := r.Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	if len(redacted) < len(data)-1 || bytes.Equal(redacted, data) {
+		t.Errorf("values should be hashed: %x", redacted)
+	}
+
+	msg := New(redacted)
+	for msg.Next() {
+		msg.Skip()
+	}
+
+	if msg.Error() != nil {
+		t.Errorf("redacted message should be valid: %e", msg.Error())
+	}
+}
+
+func TestRedactorFromDescriptor(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+	data := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1 username: "name"
+		orders { id: 2 open: true }
+		by_name { key: "a" value { id: 3 open: true } }`))
+
+	redacted, err := RedactorFromDescriptor(md, RedactZero).Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	result := dynamicpb.NewMessage(md)
+	if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(redacted, result); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := newLegacy(t, "Customer", `
+		id: 1 username: ""
+		orders { id: 2 open: true }
+		by_name { key: "a" value { id: 3 open: true } }`)
+	if !proto.Equal(result, expected) {
+		t.Errorf("incorrect message: %v", result)
+	}
+
+	if v := result.Get(md.Fields().ByName(protoreflect.Name("username"))); v.String() != "" {
+		t.Errorf("username should be zero: %v", v)
+	}
+}
+
+func TestRedactor_hashDescriptor(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 2, Open: true}, {Id: 3}},
+		FavoriteIds: []int64{4, 5, 6},
+	}
+	md := c.ProtoReflect().Descriptor()
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	r := NewRedactor(RedactHash, Path{2}, Path{3}, Path{4})
+	r.Descriptor = md
+	redacted, err := r.Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	result := &testmsg.Customer{}
+	if err := proto.Unmarshal(redacted, result); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	sum := sha256.Sum256([]byte("name"))
+	expected := &testmsg.Customer{
+		Id:       1,
+		Username: hex.EncodeToString(sum[:]),
+		Orders:   []*testmsg.Order{{}, {}},
+	}
+	if !proto.Equal(result, expected) {
+		t.Errorf("incorrect message: %v", result)
+	}
+
+	// debug_redact on an embedded message
+	legacy := legacyFile.Messages().ByName("Customer")
+
+	data = marshalPartial(t, newLegacy(t, "Customer", `id: 1 username: "name" last { id: 2 open: true }`))
+	redacted, err = RedactorFromDescriptor(legacy, RedactHash).Redact(data)
+	if err != nil {
+		t.Fatalf("unable to redact: %e", err)
+	}
+
+	decoded := dynamicpb.NewMessage(legacy)
+	if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(redacted, decoded); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	if v := decoded.Get(legacy.Fields().ByName("username")).String(); v != hex.EncodeToString(sum[:]) {
+		t.Errorf("username should be hashed: %v", v)
+	}
+
+	if v := decoded.Get(legacy.Fields().ByName("last")).Message(); !v.IsValid() || v.Has(v.Descriptor().Fields().ByName("id")) {
+		t.Errorf("last should be zeroed: %v", v)
+	}
+}
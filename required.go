@@ -0,0 +1,118 @@
+package pbr
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RequiredError is returned when required fields are missing.
+// It contains the path of every missing field,
+// e.g. 'orders[1].id' for the id of the second order.
+type RequiredError struct {
+	Paths []string
+}
+
+func (e *RequiredError) Error() string {
+	return "protoscan: required fields not set: " + strings.Join(e.Paths, ", ")
+}
+
+// CheckRequired scans the message and all its embedded messages
+// and returns a *RequiredError if any required fields are missing.
+// The values of the fields are not decoded.
+func CheckRequired(data []byte, md protoreflect.MessageDescriptor) error {
+	var paths []string
+	if err := missingRequired([][]byte{data}, md, "", &paths); err != nil {
+		return err
+	}
+
+	if len(paths) > 0 {
+		return &RequiredError{Paths: paths}
+	}
+
+	return nil
+}
+
+// MissingRequired returns the required fields of the descriptor
+// that are not in the set. The set is usually filled during
+// a scan using Message.Track.
+// Embedded messages are not checked.
+func MissingRequired(md protoreflect.MessageDescriptor, set *FieldSet) []protoreflect.FieldDescriptor {
+	var result []protoreflect.FieldDescriptor
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Cardinality() == protoreflect.Required && !set.Has(int(fd.Number())) {
+			result = append(result, fd)
+		}
+	}
+
+	return result
+}
+
+// missingRequired checks a message that is encoded in one or more chunks,
+// an embedded message may be split and the chunks are merged.
+func missingRequired(chunks [][]byte, md protoreflect.MessageDescriptor, prefix string, paths *[]string) error {
+	var (
+		set      FieldSet
+		embedded map[int][][]byte
+	)
+
+	fields := md.Fields()
+	msg := &Message{}
+	msg.Track(&set)
+	for _, data := range chunks {
+		msg.Reset(data)
+		for msg.Next() {
+			fd := fields.ByNumber(protoreflect.FieldNumber(msg.fieldNumber))
+			if fd == nil || fd.Kind() != protoreflect.MessageKind || msg.wireType != WireTypeLengthDelimited {
+				msg.Skip()
+				continue
+			}
+
+			d, err := msg.MessageData()
+			if err != nil {
+				return err
+			}
+
+			if embedded == nil {
+				embedded = make(map[int][][]byte)
+			}
+			embedded[msg.fieldNumber] = append(embedded[msg.fieldNumber], d)
+		}
+
+		if msg.err != nil {
+			return msg.err
+		}
+	}
+
+	for _, fd := range MissingRequired(md, &set) {
+		*paths = append(*paths, prefix+string(fd.Name()))
+	}
+
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		occurrences := embedded[int(fd.Number())]
+		if len(occurrences) == 0 {
+			continue
+		}
+
+		name := prefix + string(fd.Name())
+		if !fd.IsList() && !fd.IsMap() {
+			if err := missingRequired(occurrences, fd.Message(), name+".", paths); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for j, d := range occurrences {
+			p := name + "[" + strconv.Itoa(j) + "]."
+			if err := missingRequired([][]byte{d}, fd.Message(), p, paths); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package pbr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckRequired(t *testing.T) {
+	md := legacyFile.Messages().ByName("Customer")
+
+	t.Run("all set", func(t *testing.T) {
+		data := marshalPartial(t, newLegacy(t, "Customer", `id: 1 orders { id: 2 open: true }`))
+		if err := CheckRequired(data, md); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		data := marshalPartial(t, newLegacy(t, "Customer", `
+			username: "name"
+			orders { id: 1 open: true }
+			orders { id: 2 }
+			last { open: false }
+			by_name { key: "a" value { id: 3 } }
+		`))
+
+		err := CheckRequired(data, md)
+		re, ok := err.(*RequiredError)
+		if !ok {
+			t.Fatalf("incorrect error: %v", err)
+		}
+
+		expected := []string{"id", "orders[1].open", "last.id", "by_name[0].value.open"}
+		if !reflect.DeepEqual(re.Paths, expected) {
+			t.Errorf("incorrect paths: %v", re.Paths)
+		}
+	})
+
+	t.Run("split embedded message", func(t *testing.T) {
+		// the 'last' message is split in two occurrences, merged it is complete.
+		data := []byte{
+			0x08, 0x01, // id
+			0x2a, 0x02, 0x08, 0x01, // last { id: 1 }
+			0x2a, 0x02, 0x10, 0x01, // last { open: true }
+		}
+
+		if err := CheckRequired(data, md); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		if err := CheckRequired([]byte{0x1a, 0x05}, md); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
+
+func TestMissingRequired(t *testing.T) {
+	md := legacyFile.Messages().ByName("Order")
+
+	set := &FieldSet{}
+	msg := New([]byte{0x10, 0x01})
+	msg.Track(set)
+	for msg.Next() {
+		msg.Skip()
+	}
+
+	missing := MissingRequired(md, set)
+	if len(missing) != 1 || missing[0].Name() != "id" {
+		t.Errorf("incorrect missing fields: %v", missing)
+	}
+}
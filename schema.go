@@ -0,0 +1,76 @@
+package pbr
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// Schema describes the fields of a message for the helpers that need
+// more than the wire format, e.g. to know if a field is repeated or
+// contains an embedded message. Either the Descriptor is set or
+// the fields are listed explicitly, fields that are not listed
+// are non-repeated scalars.
+// A nil Schema means nothing is known about the fields.
+type Schema struct {
+	Descriptor protoreflect.MessageDescriptor
+
+	// Messages are the fields that contain embedded messages,
+	// with the schema of the embedded message.
+	Messages map[int]*Schema
+
+	// Repeated are the repeated fields, including repeated embedded messages.
+	Repeated map[int]bool
+}
+
+// known returns true if the field is described by the schema.
+func (s *Schema) known(fieldNumber int) bool {
+	if s == nil {
+		return false
+	}
+
+	if s.Descriptor != nil {
+		return s.field(fieldNumber) != nil
+	}
+
+	return true
+}
+
+// message returns the schema of the embedded message
+// and true if the field contains embedded messages.
+func (s *Schema) message(fieldNumber int) (*Schema, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	if s.Descriptor != nil {
+		fd := s.field(fieldNumber)
+		if fd == nil || fd.Kind() != protoreflect.MessageKind {
+			return nil, false
+		}
+
+		return &Schema{Descriptor: fd.Message()}, true
+	}
+
+	sub, ok := s.Messages[fieldNumber]
+	return sub, ok
+}
+
+// repeated returns true if the field is a known repeated field.
+func (s *Schema) repeated(fieldNumber int) bool {
+	if s == nil {
+		return false
+	}
+
+	if s.Descriptor != nil {
+		fd := s.field(fieldNumber)
+		return fd != nil && fd.Cardinality() == protoreflect.Repeated
+	}
+
+	return s.Repeated[fieldNumber]
+}
+
+// field returns the field descriptor if the schema has a descriptor.
+func (s *Schema) field(fieldNumber int) protoreflect.FieldDescriptor {
+	if s == nil || s.Descriptor == nil {
+		return nil
+	}
+
+	return s.Descriptor.Fields().ByNumber(protoreflect.FieldNumber(fieldNumber))
+}
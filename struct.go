@@ -0,0 +1,246 @@
+package pbr
+
+// Struct reads an embedded google.protobuf.Struct message
+// into a map of native Go values, see Value.
+func (m *Message) Struct() (map[string]any, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeStruct(data)
+}
+
+// Value reads an embedded google.protobuf.Value message into a native Go value:
+// nil for null, float64 for numbers, string, bool,
+// map[string]any for structs and []any for lists.
+func (m *Message) Value() (any, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeValue(data)
+}
+
+// ListValue reads an embedded google.protobuf.ListValue
+// message into a slice of native Go values, see Value.
+func (m *Message) ListValue() ([]any, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeList(data)
+}
+
+// StructGet returns the value at the path of keys in an embedded
+// google.protobuf.Struct message, see Value for the types.
+// Only the requested keys are decoded, everything else is skipped.
+// Returns false if a key is not found or a value on the path is not a struct.
+func (m *Message) StructGet(path ...string) (any, bool, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for i, key := range path {
+		value, ok, err := structEntry(data, key)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+
+		if i == len(path)-1 {
+			v, err := decodeValue(value)
+			if err != nil {
+				return nil, false, err
+			}
+
+			return v, true, nil
+		}
+
+		// the next key must be in a struct value,
+		// the last kind of the value oneof wins.
+		data = nil
+		msg := New(value)
+		for msg.Next() {
+			if msg.fieldNumber >= 1 && msg.fieldNumber <= 6 {
+				data = nil
+			}
+
+			if msg.fieldNumber != 5 || msg.wireType != WireTypeLengthDelimited {
+				msg.Skip()
+				continue
+			}
+
+			if data, err = msg.MessageData(); err != nil {
+				return nil, false, err
+			}
+		}
+
+		if msg.err != nil {
+			return nil, false, msg.err
+		}
+
+		if data == nil {
+			return nil, false, nil
+		}
+	}
+
+	v, err := decodeStruct(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return v, true, nil
+}
+
+// structEntry returns the encoded value for the key, the last one if repeated.
+func structEntry(data []byte, key string) (value []byte, found bool, err error) {
+	msg := New(data)
+	entry := &Message{}
+	for msg.Next() {
+		if msg.fieldNumber != 1 || msg.wireType != WireTypeLengthDelimited {
+			msg.Skip()
+			continue
+		}
+
+		if entry, err = msg.Message(entry); err != nil {
+			return nil, false, err
+		}
+
+		var (
+			k string
+			v []byte
+		)
+		for entry.Next() {
+			switch {
+			case entry.fieldNumber == 1 && entry.wireType == WireTypeLengthDelimited:
+				k, err = entry.String()
+			case entry.fieldNumber == 2 && entry.wireType == WireTypeLengthDelimited:
+				v, err = entry.MessageData()
+			default:
+				entry.Skip()
+			}
+
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
+		if entry.err != nil {
+			return nil, false, entry.err
+		}
+
+		if k == key {
+			value, found = v, true
+		}
+	}
+
+	return value, found, msg.err
+}
+
+func decodeStruct(data []byte) (map[string]any, error) {
+	result := make(map[string]any)
+	msg := New(data)
+	entry := &Message{}
+	for msg.Next() {
+		if msg.fieldNumber != 1 || msg.wireType != WireTypeLengthDelimited {
+			msg.Skip()
+			continue
+		}
+
+		var err error
+		if entry, err = msg.Message(entry); err != nil {
+			return nil, err
+		}
+
+		var (
+			key   string
+			value any
+		)
+		for entry.Next() {
+			switch {
+			case entry.fieldNumber == 1 && entry.wireType == WireTypeLengthDelimited:
+				key, err = entry.String()
+			case entry.fieldNumber == 2 && entry.wireType == WireTypeLengthDelimited:
+				value, err = entry.Value()
+			default:
+				entry.Skip()
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if entry.err != nil {
+			return nil, entry.err
+		}
+
+		result[key] = value
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	return result, nil
+}
+
+func decodeValue(data []byte) (v any, err error) {
+	msg := New(data)
+	for msg.Next() {
+		switch {
+		case msg.fieldNumber == 1 && msg.wireType == WireTypeVarint:
+			msg.Skip()
+			v = nil
+		case msg.fieldNumber == 2 && msg.wireType == WireType64bit:
+			v, err = msg.Double()
+		case msg.fieldNumber == 3 && msg.wireType == WireTypeLengthDelimited:
+			v, err = msg.String()
+		case msg.fieldNumber == 4 && msg.wireType == WireTypeVarint:
+			v, err = msg.Bool()
+		case msg.fieldNumber == 5 && msg.wireType == WireTypeLengthDelimited:
+			v, err = msg.Struct()
+		case msg.fieldNumber == 6 && msg.wireType == WireTypeLengthDelimited:
+			v, err = msg.ListValue()
+		default:
+			msg.Skip()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	return v, nil
+}
+
+func decodeList(data []byte) ([]any, error) {
+	result := make([]any, 0)
+	msg := New(data)
+	for msg.Next() {
+		if msg.fieldNumber != 1 || msg.wireType != WireTypeLengthDelimited {
+			msg.Skip()
+			continue
+		}
+
+		v, err := msg.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, v)
+	}
+
+	if msg.err != nil {
+		return nil, msg.err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package pbr
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestMessage_Struct(t *testing.T) {
+	expected := map[string]any{
+		"null":   nil,
+		"number": 1.5,
+		"string": "abc",
+		"bool":   true,
+		"struct": map[string]any{
+			"a": map[string]any{"b": "c"},
+			"":  "empty",
+		},
+		"list": []any{1.0, "two", []any{}, map[string]any{}},
+	}
+
+	s, err := structpb.NewStruct(expected)
+	if err != nil {
+		t.Fatalf("unable to create struct: %e", err)
+	}
+
+	v, err := embedded(t, s).Struct()
+	if err != nil {
+		t.Fatalf("unable to read struct: %e", err)
+	}
+
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("incorrect value: %v", v)
+	}
+
+	value, _ := structpb.NewValue([]any{"a", 1.0})
+	if v, err := embedded(t, value).Value(); err != nil || !reflect.DeepEqual(v, []any{"a", 1.0}) {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	list, _ := structpb.NewList([]any{true, nil})
+	if v, err := embedded(t, list).ListValue(); err != nil || !reflect.DeepEqual(v, []any{true, nil}) {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		cases := []struct {
+			path     []string
+			expected any
+			found    bool
+		}{
+			{path: []string{"number"}, expected: 1.5, found: true},
+			{path: []string{"struct", "a", "b"}, expected: "c", found: true},
+			{path: []string{"struct", ""}, expected: "empty", found: true},
+			{path: []string{"struct", "a"}, expected: map[string]any{"b": "c"}, found: true},
+			{path: []string{"null"}, expected: nil, found: true},
+			{path: []string{"missing"}},
+			{path: []string{"string", "a"}},
+			{path: []string{"struct", "a", "missing"}},
+			{path: nil, expected: expected, found: true},
+		}
+
+		for _, c := range cases {
+			v, found, err := embedded(t, s).StructGet(c.path...)
+			if err != nil {
+				t.Fatalf("unexpected error: %e", err)
+			}
+
+			if found != c.found || !reflect.DeepEqual(v, c.expected) {
+				t.Errorf("incorrect value for %v: %v %v", c.path, v, found)
+			}
+		}
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		msg := New([]byte{0x0a, 0x04, 0x0a, 0x02, 0x12, 0x05})
+		msg.Next()
+		if _, err := msg.Struct(); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,404 @@
+package pbr
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Text returns the protobuf text format of the encoded message.
+// With a descriptor the field and enum names are used,
+// without one, or for unknown fields, the field numbers are used
+// and length-delimited values that look like a message are printed as one.
+func Text(data []byte, md protoreflect.MessageDescriptor) ([]byte, error) {
+	p := &textPrinter{}
+	if err := p.message(New(data), md, 0); err != nil {
+		return nil, err
+	}
+
+	return p.buf, nil
+}
+
+// WriteText writes the protobuf text format of the encoded message, see Text.
+func WriteText(w io.Writer, data []byte, md protoreflect.MessageDescriptor) error {
+	text, err := Text(data, md)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(text)
+	return err
+}
+
+type textPrinter struct {
+	buf    []byte
+	indent int
+}
+
+// message prints the fields until the end of the data or the
+// end of the group with the given field number.
+func (p *textPrinter) message(msg *Message, md protoreflect.MessageDescriptor, group int) error {
+	for msg.Next() {
+		if msg.wireType == WireTypeEndGroup {
+			if msg.fieldNumber != group {
+				return ErrInvalidWireType
+			}
+			return nil
+		}
+
+		var fd protoreflect.FieldDescriptor
+		if md != nil {
+			fd = md.Fields().ByNumber(protoreflect.FieldNumber(msg.fieldNumber))
+		}
+
+		if fd != nil && p.known(msg, fd) {
+			if err := p.field(msg, fd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := p.unknown(msg); err != nil {
+			return err
+		}
+	}
+
+	if msg.err != nil {
+		return msg.err
+	}
+
+	if group != 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// known returns true if the wire type matches the kind of the field.
+func (p *textPrinter) known(msg *Message, fd protoreflect.FieldDescriptor) bool {
+	wireType := kindWireType(fd.Kind())
+	if msg.wireType == wireType {
+		return true
+	}
+
+	return fd.IsList() && msg.wireType == WireTypeLengthDelimited &&
+		(wireType == WireTypeVarint || wireType == WireType32bit || wireType == WireType64bit)
+}
+
+func (p *textPrinter) field(msg *Message, fd protoreflect.FieldDescriptor) error {
+	name := fd.TextName()
+	switch {
+	case fd.Kind() == protoreflect.GroupKind:
+		p.open(name)
+		if err := p.message(msg, fd.Message(), msg.fieldNumber); err != nil {
+			return err
+		}
+		p.close()
+		return nil
+	case fd.Kind() == protoreflect.MessageKind:
+		sub, err := msg.Message(nil)
+		if err != nil {
+			return err
+		}
+
+		p.open(name)
+		if err := p.message(sub, fd.Message(), 0); err != nil {
+			return err
+		}
+		p.close()
+		return nil
+	case msg.wireType == WireTypeLengthDelimited && fd.Kind() != protoreflect.StringKind && fd.Kind() != protoreflect.BytesKind:
+		// packed repeated values
+		iter, err := msg.Iterator(nil)
+		if err != nil {
+			return err
+		}
+
+		p.line(name)
+		p.buf = append(p.buf, ": ["...)
+		for i := 0; iter.HasNext(); i++ {
+			if i > 0 {
+				p.buf = append(p.buf, ", "...)
+			}
+
+			if err := p.value(&iter.base, fd); err != nil {
+				return err
+			}
+		}
+		p.buf = append(p.buf, "]\n"...)
+		return nil
+	}
+
+	p.line(name)
+	p.buf = append(p.buf, ": "...)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+		p.buf = appendQuoted(p.buf, v, true)
+	case protoreflect.BytesKind:
+		v, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+		p.buf = appendQuoted(p.buf, v, false)
+	default:
+		if err := p.value(&msg.base, fd); err != nil {
+			return err
+		}
+	}
+	p.buf = append(p.buf, '\n')
+
+	return nil
+}
+
+// value prints a scalar value that can be packed.
+func (p *textPrinter) value(b *base, fd protoreflect.FieldDescriptor) error {
+	var err error
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		var v bool
+		v, err = b.Bool()
+		p.buf = strconv.AppendBool(p.buf, v)
+	case protoreflect.EnumKind:
+		var v int32
+		v, err = b.Int32()
+		if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(v)); ev != nil {
+			p.buf = append(p.buf, ev.Name()...)
+		} else {
+			p.buf = strconv.AppendInt(p.buf, int64(v), 10)
+		}
+	case protoreflect.Int32Kind:
+		var v int32
+		v, err = b.Int32()
+		p.buf = strconv.AppendInt(p.buf, int64(v), 10)
+	case protoreflect.Sint32Kind:
+		var v int32
+		v, err = b.Sint32()
+		p.buf = strconv.AppendInt(p.buf, int64(v), 10)
+	case protoreflect.Uint32Kind:
+		var v uint32
+		v, err = b.Uint32()
+		p.buf = strconv.AppendUint(p.buf, uint64(v), 10)
+	case protoreflect.Int64Kind:
+		var v int64
+		v, err = b.Int64()
+		p.buf = strconv.AppendInt(p.buf, v, 10)
+	case protoreflect.Sint64Kind:
+		var v int64
+		v, err = b.Sint64()
+		p.buf = strconv.AppendInt(p.buf, v, 10)
+	case protoreflect.Uint64Kind:
+		var v uint64
+		v, err = b.Uint64()
+		p.buf = strconv.AppendUint(p.buf, v, 10)
+	case protoreflect.Sfixed32Kind:
+		var v int32
+		v, err = b.Sfixed32()
+		p.buf = strconv.AppendInt(p.buf, int64(v), 10)
+	case protoreflect.Fixed32Kind:
+		var v uint32
+		v, err = b.Fixed32()
+		p.buf = strconv.AppendUint(p.buf, uint64(v), 10)
+	case protoreflect.FloatKind:
+		var v float32
+		v, err = b.Float()
+		p.buf = appendFloat(p.buf, float64(v), 32)
+	case protoreflect.Sfixed64Kind:
+		var v int64
+		v, err = b.Sfixed64()
+		p.buf = strconv.AppendInt(p.buf, v, 10)
+	case protoreflect.Fixed64Kind:
+		var v uint64
+		v, err = b.Fixed64()
+		p.buf = strconv.AppendUint(p.buf, v, 10)
+	case protoreflect.DoubleKind:
+		var v float64
+		v, err = b.Double()
+		p.buf = appendFloat(p.buf, v, 64)
+	default:
+		err = ErrInvalidWireType
+	}
+
+	return err
+}
+
+// unknown prints a field using its number and a guess of the type.
+func (p *textPrinter) unknown(msg *Message) error {
+	name := strconv.Itoa(msg.fieldNumber)
+	switch msg.wireType {
+	case WireTypeVarint:
+		v, err := msg.Uint64()
+		if err != nil {
+			return err
+		}
+		p.line(name)
+		p.buf = append(p.buf, ": "...)
+		p.buf = strconv.AppendUint(p.buf, v, 10)
+	case WireType32bit:
+		v, err := msg.Fixed32()
+		if err != nil {
+			return err
+		}
+		p.line(name)
+		p.buf = fmt.Appendf(p.buf, ": 0x%08x", v)
+	case WireType64bit:
+		v, err := msg.Fixed64()
+		if err != nil {
+			return err
+		}
+		p.line(name)
+		p.buf = fmt.Appendf(p.buf, ": 0x%016x", v)
+	case WireTypeLengthDelimited:
+		v, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+
+		if looksLikeMessage(v) {
+			p.open(name)
+			if err := p.message(New(v), nil, 0); err != nil {
+				return err
+			}
+			p.close()
+			return nil
+		}
+
+		p.line(name)
+		p.buf = append(p.buf, ": "...)
+		p.buf = appendQuoted(p.buf, v, utf8.Valid(v))
+	case WireTypeStartGroup:
+		p.open(name)
+		if err := p.message(msg, nil, msg.fieldNumber); err != nil {
+			return err
+		}
+		p.close()
+		return nil
+	default:
+		return ErrInvalidWireType
+	}
+	p.buf = append(p.buf, '\n')
+
+	return nil
+}
+
+func (p *textPrinter) line(name string) {
+	for i := 0; i < p.indent; i++ {
+		p.buf = append(p.buf, "  "...)
+	}
+	p.buf = append(p.buf, name...)
+}
+
+func (p *textPrinter) open(name string) {
+	p.line(name)
+	p.buf = append(p.buf, " {\n"...)
+	p.indent++
+}
+
+func (p *textPrinter) close() {
+	p.indent--
+	p.line("}\n")
+}
+
+// looksLikeMessage returns true if the data can be fully scanned as
+// a message with valid field numbers and without groups.
+func looksLikeMessage(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	msg := New(data)
+	for msg.Next() {
+		if msg.fieldNumber == 0 || msg.wireType == WireTypeStartGroup || msg.wireType == WireTypeEndGroup {
+			return false
+		}
+		msg.Skip()
+	}
+
+	return msg.err == nil
+}
+
+// kindWireType returns the wire type of non-packed values of the kind.
+func kindWireType(k protoreflect.Kind) int {
+	switch k {
+	case protoreflect.BoolKind, protoreflect.EnumKind,
+		protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Uint64Kind:
+		return WireTypeVarint
+	case protoreflect.Sfixed32Kind, protoreflect.Fixed32Kind, protoreflect.FloatKind:
+		return WireType32bit
+	case protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind, protoreflect.DoubleKind:
+		return WireType64bit
+	case protoreflect.GroupKind:
+		return WireTypeStartGroup
+	default:
+		return WireTypeLengthDelimited
+	}
+}
+
+func appendFloat(buf []byte, v float64, bitSize int) []byte {
+	switch {
+	case math.IsInf(v, 1):
+		return append(buf, "inf"...)
+	case math.IsInf(v, -1):
+		return append(buf, "-inf"...)
+	case math.IsNaN(v):
+		return append(buf, "nan"...)
+	}
+
+	return strconv.AppendFloat(buf, v, 'g', -1, bitSize)
+}
+
+// appendQuoted appends the value as a quoted text format string.
+// Printable UTF-8 is kept for strings, otherwise non-printable
+// and non-ASCII bytes are octal escaped.
+func appendQuoted(buf []byte, v []byte, isString bool) []byte {
+	buf = append(buf, '"')
+	for len(v) > 0 {
+		c := v[0]
+		switch c {
+		case '"':
+			buf = append(buf, `\"`...)
+		case '\\':
+			buf = append(buf, `\\`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		case '\t':
+			buf = append(buf, `\t`...)
+		default:
+			if isString && c >= utf8.RuneSelf {
+				r, size := utf8.DecodeRune(v)
+				if r != utf8.RuneError && strconv.IsPrint(r) {
+					buf = append(buf, v[:size]...)
+				} else {
+					for _, b := range v[:size] {
+						buf = appendOctal(buf, b)
+					}
+				}
+				v = v[size:]
+				continue
+			}
+
+			if c < 0x20 || c >= 0x7f {
+				buf = appendOctal(buf, c)
+			} else {
+				buf = append(buf, c)
+			}
+		}
+		v = v[1:]
+	}
+
+	return append(buf, '"')
+}
+
+func appendOctal(buf []byte, c byte) []byte {
+	return append(buf, '\\', '0'+(c>>6), '0'+((c>>3)&7), '0'+(c&7))
+}
@@ -0,0 +1,142 @@
+package pbr
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestText(t *testing.T) {
+	c := &testmsg.Customer{
+		Id:       123,
+		Username: "na\"me\n\x01é",
+		Orders: []*testmsg.Order{
+			{Id: 1, Open: true, Items: []*testmsg.Item{{Id: 2}}},
+			{Id: 3},
+		},
+		FavoriteIds: []int64{1, -2, 3},
+	}
+
+	data, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	text, err := Text(data, c.ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("unable to format: %e", err)
+	}
+
+	expected := `id: 123
+username: "na\"me\n\001é"
+orders {
+  id: 1
+  open: true
+  items {
+    id: 2
+  }
+}
+orders {
+  id: 3
+}
+favorite_ids: [1, -2, 3]
+`
+	if string(text) != expected {
+		t.Errorf("incorrect text:\n%s", text)
+	}
+
+	result := &testmsg.Customer{}
+	if err := prototext.Unmarshal(text, result); err != nil {
+		t.Fatalf("unable to parse text: %e", err)
+	}
+
+	if !proto.Equal(result, c) {
+		t.Errorf("incorrect round trip: %v", result)
+	}
+
+	t.Run("without descriptor", func(t *testing.T) {
+		text, err := Text(data, nil)
+		if err != nil {
+			t.Fatalf("unable to format: %e", err)
+		}
+
+		expected := `1: 123
+2: "na\"me\n\001é"
+3 {
+  1: 1
+  2: 1
+  3 {
+    1: 2
+  }
+}
+3 {
+  1: 3
+}
+4: "\001\376\377\377\377\377\377\377\377\377\001\003"
+`
+		if string(text) != expected {
+			t.Errorf("incorrect text:\n%s", text)
+		}
+	})
+
+	t.Run("scalars", func(t *testing.T) {
+		s := &testmsg.Scalar{
+			Flt:  float32(math.Inf(1)),
+			Dbl:  math.NaN(),
+			S32:  -5,
+			F64:  7,
+			Byte: []byte{0xff, 'a'},
+		}
+
+		data, _ := proto.Marshal(s)
+		var buf bytes.Buffer
+		if err := WriteText(&buf, data, s.ProtoReflect().Descriptor()); err != nil {
+			t.Fatalf("unable to format: %e", err)
+		}
+
+		expected := "flt: inf\ndbl: nan\ns32: -5\nf64: 7\nbyte: \"\\377a\"\n"
+		if buf.String() != expected {
+			t.Errorf("incorrect text:\n%s", buf.String())
+		}
+
+		text, _ := Text(data, nil)
+		expected = "1: 0x7f800000\n2: 0x7ff8000000000001\n7: 9\n10: 0x0000000000000007\n15: \"\\377a\"\n"
+		if string(text) != expected {
+			t.Errorf("incorrect text:\n%s", text)
+		}
+	})
+
+	t.Run("enum", func(t *testing.T) {
+		v := structpb.NewNullValue()
+		data, _ := proto.Marshal(&structpb.ListValue{Values: []*structpb.Value{v}})
+		text, err := Text(data, (&structpb.ListValue{}).ProtoReflect().Descriptor())
+		if err != nil {
+			t.Fatalf("unable to format: %e", err)
+		}
+
+		if string(text) != "values {\n  null_value: NULL_VALUE\n}\n" {
+			t.Errorf("incorrect text:\n%s", text)
+		}
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		data := []byte{0x0b, 0x10, 0x01, 0x0c, 0x18, 0x02}
+		text, err := Text(data, nil)
+		if err != nil {
+			t.Fatalf("unable to format: %e", err)
+		}
+
+		if string(text) != "1 {\n  2: 1\n}\n3: 2\n" {
+			t.Errorf("incorrect text:\n%s", text)
+		}
+
+		if _, err := Text([]byte{0x0b, 0x10, 0x01}, nil); err == nil {
+			t.Errorf("expected an error for an unterminated group")
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package pbr
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Unmarshal decodes the message into the struct pointed to by v
+// using the pbr struct tags of its fields, see the example below.
+// Fields are reset first, but slices and maps are reused.
+// Unknown fields are skipped, repeated scalars may be packed or not.
+// Repeated occurrences of a non-repeated struct field are merged.
+//
+//	type Customer struct {
+//		ID          int64            `pbr:"1"`
+//		Username    string           `pbr:"2"`
+//		Orders      []*Order         `pbr:"3"`
+//		FavoriteIDs []int64          `pbr:"4,packed"`
+//		Scores      map[string]int32 `pbr:"5,sint32"`
+//	}
+//
+// The decode plan of every type is built once and cached.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: Unmarshal requires a pointer to a struct, got %T", ErrInvalidTag, v)
+	}
+
+	p, err := planFor(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	p.reset(rv.Elem())
+	return p.decode(New(data), rv.Elem())
+}
+
+// reset sets the fields to their zero value keeping the slices and maps.
+func (p *structPlan) reset(rv reflect.Value) {
+	for _, f := range p.fields {
+		fv := rv.Field(f.index)
+		switch {
+		case f.isMap:
+			if !fv.IsNil() {
+				fv.Clear()
+			}
+		case f.repeated || f.kind == protoreflect.BytesKind:
+			if !fv.IsNil() {
+				fv.SetLen(0)
+			}
+		case f.message != nil && !f.pointer:
+			f.message.reset(fv)
+		default:
+			fv.SetZero()
+		}
+	}
+}
+
+func (p *structPlan) decode(msg *Message, rv reflect.Value) error {
+	for msg.Next() {
+		f, ok := p.numbers[msg.FieldNumber()]
+		if !ok {
+			msg.Skip()
+			continue
+		}
+
+		fv := rv.Field(f.index)
+		var err error
+		switch {
+		case f.isMap:
+			err = f.decodeEntry(msg, fv)
+		case f.repeated:
+			err = f.decodeRepeated(msg, fv)
+		default:
+			err = f.decodeValue(msg, fv)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+
+	return msg.Error()
+}
+
+// decodeRepeated appends the values of the packed or unpacked occurrence.
+func (f *fieldPlan) decodeRepeated(msg *Message, fv reflect.Value) error {
+	wireType := kindWireType(f.kind)
+	if msg.WireType() == WireTypeLengthDelimited && wireType != WireTypeLengthDelimited {
+		iter, err := msg.Iterator(nil)
+		if err != nil {
+			return err
+		}
+
+		for iter.HasNext() {
+			if err := decodeKind(&iter.base, f.kind, grow(fv)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return f.decodeValue(msg, grow(fv))
+}
+
+// decodeValue sets the value of the field, embedded messages are merged.
+func (f *fieldPlan) decodeValue(msg *Message, fv reflect.Value) error {
+	if msg.WireType() != kindWireType(f.kind) {
+		return ErrInvalidWireType
+	}
+
+	switch f.kind {
+	case protoreflect.MessageKind:
+		sub, err := msg.Message(nil)
+		if err != nil {
+			return err
+		}
+
+		if f.pointer {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+
+		return f.message.decode(sub, fv)
+	case protoreflect.StringKind:
+		v, err := msg.String()
+		if err != nil {
+			return err
+		}
+
+		fv.SetString(v)
+		return nil
+	case protoreflect.BytesKind:
+		v, err := msg.Bytes()
+		if err != nil {
+			return err
+		}
+
+		fv.SetBytes(append(fv.Bytes()[:0], v...))
+		return nil
+	}
+
+	return decodeKind(&msg.base, f.kind, fv)
+}
+
+// decodeEntry decodes a map entry and sets it in the map.
+func (f *fieldPlan) decodeEntry(msg *Message, fv reflect.Value) error {
+	if msg.WireType() != WireTypeLengthDelimited {
+		return ErrInvalidWireType
+	}
+
+	entry, err := msg.Message(nil)
+	if err != nil {
+		return err
+	}
+
+	if fv.IsNil() {
+		fv.Set(reflect.MakeMap(fv.Type()))
+	}
+
+	key := reflect.New(fv.Type().Key()).Elem()
+	value := reflect.New(f.elem).Elem()
+	keyPlan := &fieldPlan{kind: f.keyKind}
+	for entry.Next() {
+		switch entry.FieldNumber() {
+		case 1:
+			err = keyPlan.decodeValue(entry, key)
+		case 2:
+			err = f.decodeValue(entry, value)
+		default:
+			entry.Skip()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if entry.Error() != nil {
+		return entry.Error()
+	}
+
+	if f.pointer && value.IsNil() {
+		value.Set(reflect.New(f.elem.Elem()))
+	}
+
+	fv.SetMapIndex(key, value)
+	return nil
+}
+
+// grow adds an element to the slice, reusing the capacity,
+// and returns it reset to the zero value.
+func grow(slice reflect.Value) reflect.Value {
+	n := slice.Len()
+	if n < slice.Cap() {
+		slice.SetLen(n + 1)
+	} else {
+		slice.Set(reflect.Append(slice, reflect.Zero(slice.Type().Elem())))
+	}
+
+	elem := slice.Index(n)
+	elem.SetZero()
+	return elem
+}
+
+// decodeKind reads a varint or fixed size value of the kind into the value.
+func decodeKind(b *base, kind protoreflect.Kind, rv reflect.Value) error {
+	switch kind {
+	case protoreflect.BoolKind:
+		v, err := b.Bool()
+		rv.SetBool(v)
+		return err
+	case protoreflect.Int32Kind, protoreflect.EnumKind:
+		v, err := b.Int32()
+		rv.SetInt(int64(v))
+		return err
+	case protoreflect.Sint32Kind:
+		v, err := b.Sint32()
+		rv.SetInt(int64(v))
+		return err
+	case protoreflect.Sfixed32Kind:
+		v, err := b.Sfixed32()
+		rv.SetInt(int64(v))
+		return err
+	case protoreflect.Int64Kind:
+		v, err := b.Int64()
+		rv.SetInt(v)
+		return err
+	case protoreflect.Sint64Kind:
+		v, err := b.Sint64()
+		rv.SetInt(v)
+		return err
+	case protoreflect.Sfixed64Kind:
+		v, err := b.Sfixed64()
+		rv.SetInt(v)
+		return err
+	case protoreflect.Uint32Kind:
+		v, err := b.Uint32()
+		rv.SetUint(uint64(v))
+		return err
+	case protoreflect.Fixed32Kind:
+		v, err := b.Fixed32()
+		rv.SetUint(uint64(v))
+		return err
+	case protoreflect.Uint64Kind:
+		v, err := b.Uint64()
+		rv.SetUint(v)
+		return err
+	case protoreflect.Fixed64Kind:
+		v, err := b.Fixed64()
+		rv.SetUint(v)
+		return err
+	case protoreflect.FloatKind:
+		v, err := b.Float()
+		rv.SetFloat(float64(v))
+		return err
+	case protoreflect.DoubleKind:
+		v, err := b.Double()
+		rv.SetFloat(v)
+		return err
+	}
+
+	return ErrInvalidWireType
+}
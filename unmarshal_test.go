@@ -0,0 +1,238 @@
+package pbr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+type taggedScalar struct {
+	Flt   float32 `pbr:"1"`
+	Dbl   float64 `pbr:"2"`
+	I32   int32   `pbr:"3"`
+	I64   int64   `pbr:"4"`
+	U32   uint32  `pbr:"5"`
+	U64   uint64  `pbr:"6"`
+	S32   int32   `pbr:"7,sint32"`
+	S64   int64   `pbr:"8,sint64"`
+	F32   uint32  `pbr:"9,fixed32"`
+	F64   uint64  `pbr:"10,fixed64"`
+	SF32  int32   `pbr:"11,sfixed32"`
+	SF64  int64   `pbr:"12,sfixed64"`
+	Bool  bool    `pbr:"13"`
+	Str   string  `pbr:"14"`
+	Byte  []byte  `pbr:"15"`
+	After bool    `pbr:"32"`
+
+	Ignored string
+}
+
+type taggedRepeated struct {
+	Flt  []float32 `pbr:"1"`
+	Dbl  []float64 `pbr:"2"`
+	I32  []int32   `pbr:"3"`
+	I64  []int64   `pbr:"4"`
+	U32  []uint32  `pbr:"5"`
+	U64  []uint64  `pbr:"6"`
+	S32  []int32   `pbr:"7,sint32"`
+	S64  []int64   `pbr:"8,sint64"`
+	F32  []uint32  `pbr:"9,packed,fixed32"`
+	F64  []uint64  `pbr:"10,packed,fixed64"`
+	SF32 []int32   `pbr:"11,sfixed32"`
+	SF64 []int64   `pbr:"12,sfixed64"`
+	Bool []bool    `pbr:"13"`
+	Str  []string  `pbr:"14"`
+	Byte [][]byte  `pbr:"15"`
+}
+
+type taggedCustomer struct {
+	ID          int64                  `pbr:"1"`
+	Username    string                 `pbr:"2"`
+	Orders      []*taggedOrder         `pbr:"3"`
+	FavoriteIDs []int64                `pbr:"4,packed"`
+	Last        *taggedOrder           `pbr:"5"`
+	ByName      map[string]taggedOrder `pbr:"6"`
+}
+
+type taggedOrder struct {
+	ID       int64        `pbr:"1"`
+	Open     bool         `pbr:"2"`
+	Items    []taggedItem `pbr:"3"`
+	Priority int32        `pbr:"4"`
+}
+
+type taggedItem struct {
+	ID int64 `pbr:"1"`
+}
+
+func TestUnmarshal_scalar(t *testing.T) {
+	data, err := proto.Marshal(&testmsg.Scalar{
+		Flt: 1.5, Dbl: -2.5, I32: -3, I64: -4, U32: 5, U64: 6,
+		S32: -7, S64: -8, F32: 9, F64: 10, Sf32: -11, Sf64: -12,
+		Bool: true, Str: "str", Byte: []byte("byte"), After: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	v := taggedScalar{Ignored: "kept"}
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := taggedScalar{
+		Flt: 1.5, Dbl: -2.5, I32: -3, I64: -4, U32: 5, U64: 6,
+		S32: -7, S64: -8, F32: 9, F64: 10, SF32: -11, SF64: -12,
+		Bool: true, Str: "str", Byte: []byte("byte"), After: true,
+		Ignored: "kept",
+	}
+	compare(t, v, expected)
+}
+
+func TestUnmarshal_repeated(t *testing.T) {
+	r := &testmsg.Repeated{
+		Flt: []float32{1, 2}, Dbl: []float64{3}, I32: []int32{-1, 2}, I64: []int64{3, 300},
+		U32: []uint32{4}, U64: []uint64{5}, S32: []int32{-6}, S64: []int64{-7},
+		F32: []uint32{8}, F64: []uint64{9}, Sf32: []int32{-10}, Sf64: []int64{-11},
+		Bool: []bool{true, false}, Str: []string{"a", "b"}, Byte: [][]byte{[]byte("c")},
+	}
+	p := &testmsg.Packed{
+		Flt: r.Flt, Dbl: r.Dbl, I32: r.I32, I64: r.I64,
+		U32: r.U32, U64: r.U64, S32: r.S32, S64: r.S64,
+		F32: r.F32, F64: r.F64, Sf32: r.Sf32, Sf64: r.Sf64,
+		Bool: r.Bool, Str: r.Str, Byte: r.Byte,
+	}
+
+	expected := taggedRepeated{
+		Flt: r.Flt, Dbl: r.Dbl, I32: r.I32, I64: r.I64,
+		U32: r.U32, U64: r.U64, S32: r.S32, S64: r.S64,
+		F32: r.F32, F64: r.F64, SF32: r.Sf32, SF64: r.Sf64,
+		Bool: r.Bool, Str: r.Str, Byte: r.Byte,
+	}
+
+	for _, m := range []proto.Message{r, p} {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("unable to marshal: %e", err)
+		}
+
+		var v taggedRepeated
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("unable to unmarshal: %e", err)
+		}
+		compare(t, v, expected)
+	}
+}
+
+func TestUnmarshal_nested(t *testing.T) {
+	data := marshalPartial(t, newLegacy(t, "Customer", `
+		id: 1 username: "name"
+		orders { id: 2 open: true items { id: 3 } items { id: 4 } }
+		orders { id: 5 }
+		favorite_ids: [6, 7]
+		last { id: 8 }
+		by_name { key: "a" value { id: 9 priority: 1 } }
+		by_name { key: "b" }`))
+	data = appendBytes(data, 5, []byte{0x10, 0x01}) // merged into last
+
+	// existing slices and maps are reused
+	favorites := make([]int64, 0, 4)
+	byName := map[string]taggedOrder{"old": {}}
+	v := taggedCustomer{ID: 10, FavoriteIDs: favorites, ByName: byName}
+
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("unable to unmarshal: %e", err)
+	}
+
+	expected := taggedCustomer{
+		ID:       1,
+		Username: "name",
+		Orders: []*taggedOrder{
+			{ID: 2, Open: true, Items: []taggedItem{{ID: 3}, {ID: 4}}},
+			{ID: 5},
+		},
+		FavoriteIDs: []int64{6, 7},
+		Last:        &taggedOrder{ID: 8, Open: true},
+		ByName: map[string]taggedOrder{
+			"a": {ID: 9, Priority: 1},
+			"b": {},
+		},
+	}
+	compare(t, v, expected)
+
+	if &v.FavoriteIDs[0] != &favorites[:1][0] {
+		t.Errorf("slice should be reused")
+	}
+
+	if reflect.ValueOf(v.ByName).Pointer() != reflect.ValueOf(byName).Pointer() {
+		t.Errorf("map should be reused")
+	}
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	data := []byte{0x08, 0x01}
+
+	var notStruct int
+	if err := Unmarshal(data, &notStruct); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("non struct should fail: %v", err)
+	}
+
+	invalid := []any{
+		&struct {
+			V string `pbr:"1,int64"`
+		}{},
+		&struct {
+			V int64 `pbr:"0"`
+		}{},
+		&struct {
+			V int64 `pbr:"1,packed"`
+		}{},
+		&struct {
+			A int64 `pbr:"1"`
+			B int64 `pbr:"1"`
+		}{},
+		&struct {
+			v int64 `pbr:"1"`
+		}{},
+		&struct {
+			V map[float64]int64 `pbr:"1"`
+		}{},
+	}
+
+	for _, v := range invalid {
+		if err := Unmarshal(data, v); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T should fail: %v", v, err)
+		}
+	}
+
+	var wrongWireType struct {
+		V string `pbr:"1"`
+	}
+	if err := Unmarshal(data, &wrongWireType); !errors.Is(err, ErrInvalidWireType) {
+		t.Errorf("wire type mismatch should fail: %v", err)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := proto.Marshal(&testmsg.Customer{
+		Id:          1,
+		Username:    "name",
+		Orders:      []*testmsg.Order{{Id: 2, Open: true}, {Id: 3}},
+		FavoriteIds: []int64{1, 2, 3},
+	})
+	if err != nil {
+		b.Fatalf("unable to marshal: %e", err)
+	}
+
+	var v taggedCustomer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatalf("unable to unmarshal: %e", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package pbr
+
+import "sync"
+
+// View is a read-only view of an encoded message.
+// Unlike a Message it has no cursor state, so it can be shared between
+// goroutines with each one deriving its own scanner using Message.
+type View struct {
+	data []byte
+
+	once  sync.Once
+	index *FieldIndex
+	err   error
+
+	mu    sync.Mutex
+	views map[int]*View
+}
+
+// NewView creates a new View of the given encoded protobuf data.
+// The data must not be modified while the view is in use.
+func NewView(data []byte) *View {
+	return &View{data: data}
+}
+
+// View returns a view of the embedded message in the current field.
+// This is the View equivalent of Message.Message.
+func (m *Message) View() (*View, error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewView(data), nil
+}
+
+// Data returns the encoded message data of the view.
+func (v *View) Data() []byte {
+	return v.data
+}
+
+// Message returns a new scanner for the view, positioned at the start.
+// Will reuse the provided Message object if provided.
+// The scanner must only be used by one goroutine at a time.
+func (v *View) Message(msg *Message) *Message {
+	if msg == nil {
+		return New(v.data)
+	}
+
+	msg.Reset(v.data)
+	return msg
+}
+
+// Index returns the field index of the view.
+// It is built on first use and cached.
+func (v *View) Index() (*FieldIndex, error) {
+	v.once.Do(func() {
+		v.index, v.err = Index(v.data)
+	})
+
+	return v.index, v.err
+}
+
+// Lookup returns a new scanner positioned on the value of
+// the last occurrence of the field, see FieldIndex.Lookup.
+func (v *View) Lookup(fieldNumber int) (*Message, bool, error) {
+	idx, err := v.Index()
+	if err != nil {
+		return nil, false, err
+	}
+
+	msg, ok := idx.Lookup(fieldNumber)
+	return msg, ok, nil
+}
+
+// View returns a view of the embedded message in the last occurrence
+// of the field. Child views are cached so their indexes are shared.
+// Returns false if the field is not in the message.
+func (v *View) View(fieldNumber int) (*View, bool, error) {
+	v.mu.Lock()
+	child, ok := v.views[fieldNumber]
+	v.mu.Unlock()
+	if ok {
+		return child, true, nil
+	}
+
+	msg, ok, err := v.Lookup(fieldNumber)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	child, err = msg.View()
+	if err != nil {
+		return nil, false, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// another goroutine may have created it in the meantime
+	if existing, ok := v.views[fieldNumber]; ok {
+		return existing, true, nil
+	}
+
+	if v.views == nil {
+		v.views = make(map[int]*View)
+	}
+	v.views[fieldNumber] = child
+
+	return child, true, nil
+}
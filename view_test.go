@@ -0,0 +1,94 @@
+package pbr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pchchv/pbr/testmsg"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestView(t *testing.T) {
+	parent := &testmsg.Parent{
+		Child: &testmsg.Child{
+			Number:  *proto.Int64(123),
+			Numbers: []int64{1, 2, 3},
+		},
+		After: *proto.Bool(true),
+	}
+
+	data, err := proto.Marshal(parent)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	view := NewView(data)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			child, ok, err := view.View(1)
+			if err != nil || !ok {
+				t.Errorf("unable to get child: %v %e", ok, err)
+				return
+			}
+
+			msg, ok, err := child.Lookup(100)
+			if err != nil || !ok {
+				t.Errorf("unable to lookup: %v %e", ok, err)
+				return
+			}
+
+			if v, err := msg.Int64(); err != nil || v != 123 {
+				t.Errorf("incorrect value: %v %e", v, err)
+			}
+
+			var count int
+			msg = view.Message(nil)
+			for msg.Next() {
+				count++
+				msg.Skip()
+			}
+
+			if count != 2 {
+				t.Errorf("incorrect count: %v", count)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c1, _, _ := view.View(1)
+	c2, _, _ := view.View(1)
+	if c1 != c2 {
+		t.Errorf("child views should be cached")
+	}
+
+	if v, ok, err := view.View(2); ok || v != nil || err != nil {
+		t.Errorf("missing field should not be found: %v %v %e", v, ok, err)
+	}
+}
+
+func TestMessage_View(t *testing.T) {
+	data, err := proto.Marshal(&testmsg.Parent{
+		Child: &testmsg.Child{Number: *proto.Int64(123)},
+	})
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	msg := New(data)
+	msg.Next()
+	view, err := msg.View()
+	if err != nil {
+		t.Fatalf("unable to get view: %e", err)
+	}
+
+	child := view.Message(msg)
+	child.Next()
+	if v, err := child.Int64(); err != nil || v != 123 {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+}
@@ -0,0 +1,165 @@
+package pbr
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrInvalidTimestamp is returned when a google.protobuf.Timestamp
+	// is outside the range 0001-01-01 to 9999-12-31 or the nanos are invalid.
+	ErrInvalidTimestamp = errors.New("protoscan: invalid timestamp")
+	// ErrInvalidDuration is returned when a google.protobuf.Duration is
+	// outside the range of +-10000 years, the nanos are invalid or
+	// the value does not fit into a time.Duration.
+	ErrInvalidDuration = errors.New("protoscan: invalid duration")
+)
+
+const (
+	// seconds from 0001-01-01T00:00:00Z to 1970-01-01T00:00:00Z
+	minTimestampSeconds = -62135596800
+	// seconds from 1970-01-01T00:00:00Z to 9999-12-31T23:59:59Z
+	maxTimestampSeconds = 253402300799
+	// seconds in 10000 years
+	maxDurationSeconds = 315576000000
+)
+
+// Timestamp reads an embedded google.protobuf.Timestamp message.
+// The time is returned in UTC.
+func (m *Message) Timestamp() (time.Time, error) {
+	secs, nanos, err := m.secondsNanos()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if secs < minTimestampSeconds || secs > maxTimestampSeconds || nanos < 0 || nanos >= 1e9 {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+
+	return time.Unix(secs, int64(nanos)).UTC(), nil
+}
+
+// Duration reads an embedded google.protobuf.Duration message.
+func (m *Message) Duration() (time.Duration, error) {
+	secs, nanos, err := m.secondsNanos()
+	if err != nil {
+		return 0, err
+	}
+
+	if secs < -maxDurationSeconds || secs > maxDurationSeconds ||
+		nanos <= -1e9 || nanos >= 1e9 ||
+		(secs > 0 && nanos < 0) || (secs < 0 && nanos > 0) {
+		return 0, ErrInvalidDuration
+	}
+
+	// time.Duration is limited to about 292 years
+	if secs > math.MaxInt64/int64(time.Second) || secs < math.MinInt64/int64(time.Second) {
+		return 0, ErrInvalidDuration
+	}
+
+	d := time.Duration(secs) * time.Second
+	if (nanos > 0 && d > math.MaxInt64-time.Duration(nanos)) ||
+		(nanos < 0 && d < math.MinInt64-time.Duration(nanos)) {
+		return 0, ErrInvalidDuration
+	}
+
+	return d + time.Duration(nanos), nil
+}
+
+// DoubleValue reads an embedded google.protobuf.DoubleValue message.
+// An empty wrapper is the zero value, the field being set is what
+// distinguishes it from an absent value.
+func (m *Message) DoubleValue() (float64, error) {
+	return wrapperValue(m, WireType64bit, (*Message).Double)
+}
+
+// FloatValue reads an embedded google.protobuf.FloatValue message.
+func (m *Message) FloatValue() (float32, error) {
+	return wrapperValue(m, WireType32bit, (*Message).Float)
+}
+
+// Int64Value reads an embedded google.protobuf.Int64Value message.
+func (m *Message) Int64Value() (int64, error) {
+	return wrapperValue(m, WireTypeVarint, (*Message).Int64)
+}
+
+// Uint64Value reads an embedded google.protobuf.UInt64Value message.
+func (m *Message) Uint64Value() (uint64, error) {
+	return wrapperValue(m, WireTypeVarint, (*Message).Uint64)
+}
+
+// Int32Value reads an embedded google.protobuf.Int32Value message.
+func (m *Message) Int32Value() (int32, error) {
+	return wrapperValue(m, WireTypeVarint, (*Message).Int32)
+}
+
+// Uint32Value reads an embedded google.protobuf.UInt32Value message.
+func (m *Message) Uint32Value() (uint32, error) {
+	return wrapperValue(m, WireTypeVarint, (*Message).Uint32)
+}
+
+// BoolValue reads an embedded google.protobuf.BoolValue message.
+func (m *Message) BoolValue() (bool, error) {
+	return wrapperValue(m, WireTypeVarint, (*Message).Bool)
+}
+
+// StringValue reads an embedded google.protobuf.StringValue message.
+func (m *Message) StringValue() (string, error) {
+	return wrapperValue(m, WireTypeLengthDelimited, (*Message).String)
+}
+
+// BytesValue reads an embedded google.protobuf.BytesValue message.
+func (m *Message) BytesValue() ([]byte, error) {
+	return wrapperValue(m, WireTypeLengthDelimited, (*Message).Bytes)
+}
+
+// secondsNanos reads the fields of a Timestamp or Duration message.
+func (m *Message) secondsNanos() (secs int64, nanos int32, err error) {
+	data, err := m.MessageData()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	msg := New(data)
+	for msg.Next() {
+		switch {
+		case msg.fieldNumber == 1 && msg.wireType == WireTypeVarint:
+			secs, err = msg.Int64()
+		case msg.fieldNumber == 2 && msg.wireType == WireTypeVarint:
+			nanos, err = msg.Int32()
+		default:
+			msg.Skip()
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return secs, nanos, msg.err
+}
+
+// wrapperValue reads the value field of the wrapper
+// types using the last occurrence of the field.
+func wrapperValue[T any](m *Message, wireType int, read func(*Message) (T, error)) (T, error) {
+	var v T
+	data, err := m.MessageData()
+	if err != nil {
+		return v, err
+	}
+
+	msg := New(data)
+	for msg.Next() {
+		if msg.fieldNumber != 1 || msg.wireType != wireType {
+			msg.Skip()
+			continue
+		}
+
+		if v, err = read(msg); err != nil {
+			return v, err
+		}
+	}
+
+	return v, msg.err
+}
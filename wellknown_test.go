@@ -0,0 +1,119 @@
+package pbr
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// embedded returns a message positioned on field 1 containing the message.
+func embedded(t testing.TB, m proto.Message) *Message {
+	t.Helper()
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("unable to marshal: %e", err)
+	}
+
+	msg := New(appendBytes(nil, 1, data))
+	msg.Next()
+	return msg
+}
+
+func TestMessage_Timestamp(t *testing.T) {
+	now := time.Date(2024, 2, 29, 12, 30, 45, 123456789, time.UTC)
+	v, err := embedded(t, timestamppb.New(now)).Timestamp()
+	if err != nil || !v.Equal(now) {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	cases := []*timestamppb.Timestamp{
+		{Seconds: maxTimestampSeconds + 1},
+		{Seconds: minTimestampSeconds - 1},
+		{Seconds: 1, Nanos: -1},
+		{Seconds: 1, Nanos: 1e9},
+	}
+
+	for _, c := range cases {
+		if _, err := embedded(t, c).Timestamp(); err != ErrInvalidTimestamp {
+			t.Errorf("incorrect error for %v: %v", c, err)
+		}
+	}
+}
+
+func TestMessage_Duration(t *testing.T) {
+	for _, d := range []time.Duration{0, 1500 * time.Millisecond, -1500 * time.Millisecond, math.MaxInt64, math.MinInt64} {
+		v, err := embedded(t, durationpb.New(d)).Duration()
+		if err != nil || v != d {
+			t.Errorf("incorrect value: %v != %v %e", v, d, err)
+		}
+	}
+
+	cases := []*durationpb.Duration{
+		{Seconds: maxDurationSeconds + 1},
+		{Seconds: 1, Nanos: -1},
+		{Seconds: -1, Nanos: 1},
+		{Nanos: 1e9},
+		{Seconds: 300 * 365 * 24 * 3600}, // valid but larger than time.Duration
+		{Seconds: math.MaxInt64 / int64(time.Second), Nanos: 999999999},
+	}
+
+	for _, c := range cases {
+		if _, err := embedded(t, c).Duration(); err != ErrInvalidDuration {
+			t.Errorf("incorrect error for %v: %v", c, err)
+		}
+	}
+}
+
+func TestMessage_wrappers(t *testing.T) {
+	if v, err := embedded(t, wrapperspb.Double(1.5)).DoubleValue(); v != 1.5 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.Float(2.5)).FloatValue(); v != 2.5 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.Int64(-3)).Int64Value(); v != -3 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.UInt64(4)).Uint64Value(); v != 4 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.Int32(-5)).Int32Value(); v != -5 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.UInt32(6)).Uint32Value(); v != 6 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.Bool(true)).BoolValue(); !v || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.String("abc")).StringValue(); v != "abc" || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	if v, err := embedded(t, wrapperspb.Bytes([]byte{1, 2})).BytesValue(); len(v) != 2 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	// the zero value is an empty message
+	if v, err := embedded(t, wrapperspb.Int64(0)).Int64Value(); v != 0 || err != nil {
+		t.Errorf("incorrect value: %v %e", v, err)
+	}
+
+	msg := New([]byte{0x0a, 0x02, 0x08})
+	msg.Next()
+	if _, err := msg.Int64Value(); err == nil {
+		t.Errorf("expected an error")
+	}
+}